@@ -0,0 +1,133 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"summarizefiles/core"
+	"sync"
+	"time"
+)
+
+// CompareCommand scans a source and destination tree side by side. Exits 0 when the trees match by summary, 1
+// when they don't and 2 on trouble.
+func CompareCommand(args []string) int {
+	var myopts core.ProgramOpts
+
+	fset := flag.NewFlagSet("compare", flag.ExitOnError)
+	RegisterSummaryFlags(fset, &myopts)
+	fset.Parse(args)
+	myopts.DetectBatch()
+
+	if fset.NArg() != 2 {
+		fmt.Println("compare requires a source and a destination directory!")
+		fset.Usage()
+		return 2
+	}
+	if err := validateOpts(&myopts); err != nil {
+		fmt.Println(err)
+		fset.Usage()
+		return 2
+	}
+
+	if err := LoadTimeOpts(&myopts); err != nil {
+		fmt.Println(err)
+		return 2
+	}
+
+	src := core.NewFileSummary(fset.Arg(0))
+	dst := core.NewFileSummary(fset.Arg(1))
+
+	myopts.GetConsoleSize()
+	src.SetDisplayRootPath(&myopts)
+	dst.SetDisplayRootPath(&myopts)
+
+	srcScanner := core.NewScanner(src.Root, &myopts, func(rec *core.FileRecord) {
+		SummarizeFile(&myopts, &src, rec)
+	})
+	dstScanner := core.NewScanner(dst.Root, &myopts, func(rec *core.FileRecord) {
+		SummarizeFile(&myopts, &dst, rec)
+	})
+	srcScanner.Error = src.AddError
+	dstScanner.Error = dst.AddError
+	srcScanner.Skip = src.AddSkipped
+	dstScanner.Skip = dst.AddSkipped
+
+	if !myopts.Batch {
+		core.ClearConsole(true)
+	}
+
+	var wg sync.WaitGroup
+	var srcErr, dstErr error
+	wg.Add(2)
+	go func() {
+		defer wg.Done()
+		srcErr = srcScanner.Run()
+	}()
+	go func() {
+		defer wg.Done()
+		dstErr = dstScanner.Run()
+	}()
+
+	done := make(chan struct{})
+	go func() {
+		wg.Wait()
+		close(done)
+	}()
+
+	// diff consistent snapshots of both summaries while the scans run
+	diff := func() []core.EntryDelta {
+		var deltas []core.EntryDelta
+		srcScanner.Locked(func() {
+			dstScanner.Locked(func() {
+				deltas = core.DiffSummaries(&src, &dst)
+			})
+		})
+		return deltas
+	}
+
+	interval := 300 * time.Millisecond
+	if myopts.Batch {
+		interval = 5 * time.Second
+	}
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for running := true; running; {
+		select {
+		case <-done:
+			running = false
+		case <-ticker.C:
+			if !myopts.Batch {
+				core.RenderCompare(&myopts, &src, &dst, diff())
+			} else if myopts.Progress {
+				srcScanner.Locked(func() { core.Progress(&myopts, &src) })
+				dstScanner.Locked(func() { core.Progress(&myopts, &dst) })
+			}
+		}
+	}
+
+	deltas := diff()
+	core.RenderCompare(&myopts, &src, &dst, deltas)
+
+	for _, err := range []error{srcErr, dstErr} {
+		if err != nil {
+			fmt.Println(err)
+		}
+	}
+	if srcErr != nil || dstErr != nil {
+		return 2
+	}
+
+	added, removed, changed := core.CountDifferences(deltas)
+	if added+removed+changed == 0 {
+		fmt.Println("Trees match by summary.")
+		return 0
+	}
+
+	fmt.Println("Mismatched groups:")
+	for _, d := range deltas {
+		if d.Status != core.DeltaSame {
+			fmt.Println(core.FormatDelta(&myopts, d))
+		}
+	}
+	return 1
+}
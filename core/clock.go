@@ -0,0 +1,70 @@
+// core package contains all the components needed by the summarizefiles utility.
+package core
+
+import (
+	"fmt"
+	"time"
+)
+
+// asOfLayouts : the layouts --as-of accepts, tried in order.
+var asOfLayouts = []string{time.RFC3339, "2006-01-02T15:04:05", "2006-01-02 15:04:05", "2006-01-02 15:04", DateOnly}
+
+// Clock is where a scan takes the current time from: grouping by age, the status line and the exported summary.
+type Clock interface {
+	Now() time.Time
+}
+
+// SystemClock type is the wall clock.
+type SystemClock struct{}
+
+// Now returns the current time.
+func (SystemClock) Now() time.Time {
+	return time.Now()
+}
+
+// FixedClock type always tells the same time, so a report can be reproduced however much later it is made.
+type FixedClock struct {
+	At time.Time
+}
+
+// Now returns the time the clock is fixed at.
+func (fc FixedClock) Now() time.Time {
+	return fc.At
+}
+
+// NewClock construct the Clock the options ask for: fixed at --as-of, read in the --tz time zone, or the wall clock.
+func NewClock(opts *ProgramOpts) (Clock, error) {
+	if opts.AsOf == "" {
+		return SystemClock{}, nil
+	}
+	loc, err := opts.Location()
+	if err != nil {
+		return nil, err
+	}
+	for _, layout := range asOfLayouts {
+		if at, err := time.ParseInLocation(layout, opts.AsOf, loc); err == nil {
+			return FixedClock{At: at}, nil
+		}
+	}
+	return nil, fmt.Errorf("bad --as-of %q, expected a date like 2024-07-01 or a time like 2024-07-01T12:00:00Z", opts.AsOf)
+}
+
+// Now is the current time by the options' clock, the wall clock when none was set.
+func (opts *ProgramOpts) Now() time.Time {
+	if opts.Clock == nil {
+		return time.Now()
+	}
+	return opts.Clock.Now()
+}
+
+// Location is the --tz time zone, local time when none was given.
+func (opts *ProgramOpts) Location() (*time.Location, error) {
+	if opts.TimeZone == "" {
+		return time.Local, nil
+	}
+	loc, err := time.LoadLocation(opts.TimeZone)
+	if err != nil {
+		return nil, fmt.Errorf("bad --tz %q: %w", opts.TimeZone, err)
+	}
+	return loc, nil
+}
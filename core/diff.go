@@ -0,0 +1,152 @@
+// core package contains all the components needed by the summarizefiles utility.
+package core
+
+import (
+	"fmt"
+	"io"
+	"sort"
+)
+
+const (
+	DeltaSame    = "same"
+	DeltaAdded   = "added"
+	DeltaRemoved = "removed"
+	DeltaChanged = "changed"
+)
+
+// EntryDelta type describes how one group / label changed between two summaries.
+type EntryDelta struct {
+	Group  string
+	Label  string
+	Status string
+	Before SummaryEntry
+	After  SummaryEntry
+}
+
+// DeltaBytes is the change in total bytes.
+func (d EntryDelta) DeltaBytes() int64 {
+	return int64(d.After.TotalBytes) - int64(d.Before.TotalBytes)
+}
+
+// DeltaFiles is the change in file count.
+func (d EntryDelta) DeltaFiles() int64 {
+	return int64(d.After.FileCount) - int64(d.Before.FileCount)
+}
+
+// DeltaLines is the change in line count.
+func (d EntryDelta) DeltaLines() int64 {
+	return int64(d.After.LineCount) - int64(d.Before.LineCount)
+}
+
+// DiffSummaries compares every group / label in two summaries. The deltas are sorted by group then label.
+func DiffSummaries(before *FileSummary, after *FileSummary) []EntryDelta {
+	deltas := make(map[string]*EntryDelta)
+	key := func(entry SummaryEntry) string {
+		return entry.Group + "\x00" + entry.Label
+	}
+
+	for _, entry := range before.AllEntries() {
+		deltas[key(entry)] = &EntryDelta{Group: entry.Group, Label: entry.Label, Status: DeltaRemoved, Before: entry}
+	}
+	for _, entry := range after.AllEntries() {
+		d, ok := deltas[key(entry)]
+		if !ok {
+			deltas[key(entry)] = &EntryDelta{Group: entry.Group, Label: entry.Label, Status: DeltaAdded, After: entry}
+			continue
+		}
+		d.After = entry
+		if d.DeltaBytes() == 0 && d.DeltaFiles() == 0 && d.DeltaLines() == 0 {
+			d.Status = DeltaSame
+		} else {
+			d.Status = DeltaChanged
+		}
+	}
+
+	ret := make([]EntryDelta, 0, len(deltas))
+	for _, d := range deltas {
+		ret = append(ret, *d)
+	}
+	sort.Slice(ret, func(i, j int) bool {
+		if ret[i].Group != ret[j].Group {
+			return ret[i].Group < ret[j].Group
+		}
+		return ret[i].Label < ret[j].Label
+	})
+	return ret
+}
+
+// CountDifferences tallies the deltas that aren't DeltaSame.
+func CountDifferences(deltas []EntryDelta) (added int, removed int, changed int) {
+	for _, d := range deltas {
+		switch d.Status {
+		case DeltaAdded:
+			added++
+		case DeltaRemoved:
+			removed++
+		case DeltaChanged:
+			changed++
+		}
+	}
+	return added, removed, changed
+}
+
+// deltaMarkers : the marker shown in front of a delta of each status.
+var deltaMarkers = map[string]string{
+	DeltaSame:    " ",
+	DeltaAdded:   "+",
+	DeltaRemoved: "-",
+	DeltaChanged: "~",
+}
+
+// FormatDelta formats a delta into a line of text. Labels that appeared are marked '+', vanished '-' and changed '~'.
+func FormatDelta(opts *ProgramOpts, d EntryDelta) string {
+	label := d.Label
+	if d.Group != "" {
+		label = d.Group + "/" + d.Label
+	}
+
+	display := fmt.Sprintf("%s %20s: %10s -> %10s (%8s) files %+d",
+		deltaMarkers[d.Status], label, humansize(d.Before.TotalBytes), humansize(d.After.TotalBytes),
+		signedsize(d.DeltaBytes()), d.DeltaFiles())
+	if opts.Lines {
+		display += fmt.Sprintf(" lines %+d", d.DeltaLines())
+	}
+	return display
+}
+
+// WriteDiff writes the deltas between two summaries followed by their totals and a one line verdict. Unchanged
+// labels are only written when showSame is set. The totals are the summaries' own, entries overlap with --dir rollups.
+func WriteDiff(w io.Writer, opts *ProgramOpts, before *FileSummary, after *FileSummary, deltas []EntryDelta,
+	showSame bool) {
+	for _, d := range deltas {
+		if d.Status == DeltaSame && !showSame {
+			continue
+		}
+		fmt.Fprintln(w, FormatDelta(opts, d))
+	}
+
+	var total EntryDelta
+	total.Before.TotalBytes, total.After.TotalBytes = before.Total, after.Total
+	total.Before.FileCount, total.After.FileCount = int32(before.Files), int32(after.Files)
+	total.Before.LineCount, total.After.LineCount = int(before.Lines), int(after.Lines)
+	added, removed, changed := CountDifferences(deltas)
+	total.Label = "total"
+	total.Status = DeltaSame
+	if added+removed+changed > 0 {
+		total.Status = DeltaChanged
+	}
+	fmt.Fprintln(w, FormatDelta(opts, total))
+	if total.Status == DeltaSame {
+		fmt.Fprintln(w, "No differences.")
+	} else {
+		fmt.Fprintf(w, "%d added, %d removed, %d changed.\n", added, removed, changed)
+	}
+}
+
+// signedsize displays a size difference in bytes with a sign and a human friendly unit.
+func signedsize(delta int64) string {
+	if delta < 0 {
+		return "-" + humansize(uint64(-delta))
+	}
+	return "+" + humansize(uint64(delta))
+}
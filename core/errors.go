@@ -0,0 +1,74 @@
+// core package contains all the components needed by the summarizefiles utility.
+package core
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"syscall"
+)
+
+// MaxStoredErrors : how many errors a FileSummary keeps. Every error is still counted, and --errors-out streams them
+// all to a file as they happen.
+const MaxStoredErrors = 1000
+
+const (
+	ErrorPermission = "perm"
+	ErrorMissing    = "gone"
+	ErrorIO         = "io"
+	ErrorPath       = "path"
+	ErrorOther      = "other"
+)
+
+// ScanError type records a path the scan couldn't fully examine, what it was doing at the time and why it failed.
+type ScanError struct {
+	Path    string `json:"path"`
+	Op      string `json:"op"`
+	Kind    string `json:"kind"`
+	Message string `json:"error"`
+	Err     error  `json:"-"`
+}
+
+// NewScanError construct a ScanError instance, classifying err.
+func NewScanError(path string, op string, err error) ScanError {
+	return ScanError{Path: path, Op: op, Kind: ErrorKind(err), Message: errorMessage(err), Err: err}
+}
+
+func (se ScanError) Error() string {
+	return fmt.Sprintf("%s %s: %s", se.Op, se.Path, se.Message)
+}
+
+func (se ScanError) Unwrap() error {
+	return se.Err
+}
+
+// ErrorKind sorts an error into one of the categories counted in the status line.
+func ErrorKind(err error) string {
+	switch {
+	case errors.Is(err, fs.ErrPermission):
+		return ErrorPermission
+	case errors.Is(err, fs.ErrNotExist):
+		// removed between being listed and being examined
+		return ErrorMissing
+	case errors.Is(err, syscall.EIO), errors.Is(err, syscall.ESTALE), errors.Is(err, io.ErrUnexpectedEOF):
+		return ErrorIO
+	case errors.Is(err, syscall.ELOOP), errors.Is(err, syscall.ENAMETOOLONG), errors.Is(err, syscall.ENOTDIR):
+		return ErrorPath
+	}
+	return ErrorOther
+}
+
+// errorMessage drops the path a *PathError repeats, the ScanError already carries it.
+func errorMessage(err error) string {
+	var perr *fs.PathError
+	if errors.As(err, &perr) {
+		return perr.Err.Error()
+	}
+	return err.Error()
+}
+
+// FormatScanError formats an error as a tab separated line for --errors-out: kind, op, path and message.
+func FormatScanError(serr ScanError) string {
+	return fmt.Sprintf("%s\t%s\t%s\t%s", serr.Kind, serr.Op, serr.Path, serr.Message)
+}
@@ -0,0 +1,475 @@
+// core package contains all the components needed by the summarizefiles utility.
+package core
+
+import (
+	"bufio"
+	"encoding/csv"
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// ExportFormats : the formats --format accepts.
+var ExportFormats = []string{"json", "csv", "tsv", "ndjson", "yaml", "markdown", "csv-wide"}
+
+// ExportEntry type is a SummaryEntry with the stable field names used by every export format.
+type ExportEntry struct {
+	Label        string     `json:"label"`
+	Group        string     `json:"group"`
+	TotalBytes   uint64     `json:"total_bytes"`
+	Allocated    uint64     `json:"allocated_bytes"`
+	FileCount    int64      `json:"file_count"`
+	LineCount    int64      `json:"line_count"`
+	CodeLines    int64      `json:"code_lines"`
+	CommentLines int64      `json:"comment_lines"`
+	BlankLines   int64      `json:"blank_lines"`
+	MinModTime   time.Time  `json:"min_mtime"`
+	MaxModTime   time.Time  `json:"max_mtime"`
+	Top          *ExportTop `json:"top,omitempty"`
+}
+
+// ExportTop type lists the largest, newest and oldest files of the scan or of an entry, best first.
+type ExportTop struct {
+	// TimeField names the timestamp the files carry as time, the --time-field of the scan.
+	TimeField string     `json:"time_field"`
+	Largest   []FileStat `json:"largest"`
+	Newest    []FileStat `json:"newest"`
+	Oldest    []FileStat `json:"oldest"`
+}
+
+// ExportReport type is the final summary of a scan as exported.
+type ExportReport struct {
+	Root      string        `json:"root"`
+	Roots     []RootSummary `json:"roots,omitempty"`
+	Mode      string        `json:"mode"`
+	AsOf      time.Time     `json:"as_of"`
+	TimeField string        `json:"time_field"`
+	// Keys are the --by keys of a cross tab, its rows being the entry groups and its columns the entry labels. Cell
+	// is the entry field the cells of the wide layout hold.
+	Keys           []string       `json:"keys,omitempty"`
+	Cell           string         `json:"cell,omitempty"`
+	TotalBytes     uint64         `json:"total_bytes"`
+	Allocated      uint64         `json:"allocated_bytes"`
+	FileCount      int64          `json:"file_count"`
+	LineCount      int64          `json:"line_count"`
+	CodeLines      int64          `json:"code_lines"`
+	CommentLines   int64          `json:"comment_lines"`
+	BlankLines     int64          `json:"blank_lines"`
+	ExceptionCount int            `json:"exception_count"`
+	HardLinks      int            `json:"hard_links"`
+	Skipped        map[string]int `json:"skipped,omitempty"`
+	MinModTime     time.Time      `json:"min_mtime"`
+	MaxModTime     time.Time      `json:"max_mtime"`
+	Top            *ExportTop     `json:"top,omitempty"`
+	Entries        []ExportEntry  `json:"entries"`
+	// pivot is the cross tab in display order, for the wide layout.
+	pivot *PivotTable
+}
+
+// NewExportEntry construct an ExportEntry from a SummaryEntry.
+func NewExportEntry(entry SummaryEntry) ExportEntry {
+	return ExportEntry{
+		Label:        entry.Label,
+		Group:        entry.Group,
+		TotalBytes:   entry.TotalBytes,
+		Allocated:    entry.AllocatedBytes,
+		FileCount:    int64(entry.FileCount),
+		LineCount:    int64(entry.LineCount),
+		CodeLines:    int64(entry.CodeLines),
+		CommentLines: int64(entry.CommentLines),
+		BlankLines:   int64(entry.BlankLines),
+		MinModTime:   entry.MinModTime,
+		MaxModTime:   entry.MaxModTime,
+	}
+}
+
+// NewExportTop construct an ExportTop from the top files kept, nil when none were.
+func NewExportTop(opts *ProgramOpts, tf *TopFiles) *ExportTop {
+	if tf == nil {
+		return nil
+	}
+	return &ExportTop{TimeField: opts.GetTimeField(), Largest: tf.Largest.Sorted(), Newest: tf.Newest.Sorted(),
+		Oldest: tf.Oldest.Sorted()}
+}
+
+// NewExportReport construct an ExportReport for a finished scan. Entries are in display order.
+func NewExportReport(opts *ProgramOpts, summ *FileSummary) ExportReport {
+	report := ExportReport{}
+	report.Root = summ.Root
+	report.Roots = summ.Roots
+	report.Mode = opts.Mode()
+	report.AsOf = opts.Now()
+	report.TimeField = opts.GetTimeField()
+	if opts.Pivot() {
+		report.Keys = opts.ByKeys()
+		report.Cell = exportCellField(opts)
+		report.pivot = NewSummaryPivot(opts, summ)
+	}
+	report.TotalBytes = summ.Total
+	report.Allocated = summ.Allocated
+	report.FileCount = summ.Files
+	report.LineCount = summ.Lines
+	report.CodeLines = summ.Code
+	report.CommentLines = summ.Comments
+	report.BlankLines = summ.Blanks
+	report.ExceptionCount = summ.ExceptionCount
+	report.HardLinks = summ.HardLinks
+	report.Skipped = summ.SkipCounts
+	report.MinModTime = summ.MinModTime
+	report.MaxModTime = summ.MaxModTime
+	report.Top = NewExportTop(opts, summ.Top)
+
+	el := SortedEntries(opts, summ)
+	report.Entries = make([]ExportEntry, 0, len(el))
+	for _, entry := range el {
+		exported := NewExportEntry(entry)
+		exported.Top = NewExportTop(opts, summ.EntryTop(entry))
+		report.Entries = append(report.Entries, exported)
+	}
+	return report
+}
+
+// ValidExportFormat reports whether --format names a known format.
+func ValidExportFormat(format string) bool {
+	for _, known := range ExportFormats {
+		if format == known {
+			return true
+		}
+	}
+	return false
+}
+
+// Pipeable reports whether stdout carries the exported summary alone, in batch mode with --format writing to stdout.
+func (opts *ProgramOpts) Pipeable() bool {
+	return opts.Batch && opts.Format != "" && opts.Output == "-"
+}
+
+// Messages is where errors and notices go: stderr when stdout is piped for the export, stdout otherwise.
+func (opts *ProgramOpts) Messages() io.Writer {
+	if opts.Pipeable() {
+		return os.Stderr
+	}
+	return os.Stdout
+}
+
+// Export writes the final summary in opts.Format to opts.Output, "-" writes to stdout.
+func Export(opts *ProgramOpts, summ *FileSummary) error {
+	out := os.Stdout
+	if opts.Output != "" && opts.Output != "-" {
+		f, err := os.Create(opts.Output)
+		if err != nil {
+			return err
+		}
+		defer f.Close()
+		out = f
+	}
+
+	outf := bufio.NewWriter(out)
+	err := WriteReport(outf, opts.Format, NewExportReport(opts, summ))
+	if err != nil {
+		return err
+	}
+	err = outf.Flush()
+	if err != nil {
+		return err
+	}
+	if out != os.Stdout {
+		return out.Sync()
+	}
+	return nil
+}
+
+// WriteReport serializes a report in one of the ExportFormats.
+func WriteReport(w io.Writer, format string, report ExportReport) error {
+	switch format {
+	case "json":
+		enc := json.NewEncoder(w)
+		enc.SetIndent("", "  ")
+		return enc.Encode(report)
+	case "ndjson":
+		return writeNDJSON(w, report)
+	case "csv":
+		return writeDelimited(w, ',', report)
+	case "tsv":
+		return writeDelimited(w, '\t', report)
+	case "yaml":
+		return writeYAML(w, report)
+	case "markdown":
+		return writeMarkdown(w, report)
+	case "csv-wide":
+		return writeWide(w, ',', report)
+	}
+	return fmt.Errorf("unknown format %q, expected one of %s", format, strings.Join(ExportFormats, ", "))
+}
+
+// writeNDJSON writes a summary line, without entries, followed by one line per entry. Each line carries a type.
+func writeNDJSON(w io.Writer, report ExportReport) error {
+	enc := json.NewEncoder(w)
+
+	header := struct {
+		Type string `json:"type"`
+		ExportReport
+		Entries []ExportEntry `json:"entries,omitempty"`
+	}{Type: "summary", ExportReport: report}
+	if err := enc.Encode(header); err != nil {
+		return err
+	}
+
+	for _, entry := range report.Entries {
+		line := struct {
+			Type string `json:"type"`
+			ExportEntry
+		}{Type: "entry", ExportEntry: entry}
+		if err := enc.Encode(line); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// exportColumns : the columns of the delimited formats. The last row holds the scan totals under the label "(total)",
+// preceded by the totals of each root when there are several.
+var exportColumns = []string{"root", "group", "label", "total_bytes", "allocated_bytes", "file_count", "line_count",
+	"code_lines", "comment_lines", "blank_lines", "min_mtime", "max_mtime", "exception_count"}
+
+// writeDelimited writes one row per entry followed by a totals row.
+func writeDelimited(w io.Writer, comma rune, report ExportReport) error {
+	outf := csv.NewWriter(w)
+	outf.Comma = comma
+
+	row := func(root string, group string, label string, entry ExportEntry, exceptions int) []string {
+		return []string{root, group, label,
+			strconv.FormatUint(entry.TotalBytes, 10), strconv.FormatUint(entry.Allocated, 10),
+			strconv.FormatInt(entry.FileCount, 10),
+			strconv.FormatInt(entry.LineCount, 10), strconv.FormatInt(entry.CodeLines, 10),
+			strconv.FormatInt(entry.CommentLines, 10), strconv.FormatInt(entry.BlankLines, 10),
+			exportTime(entry.MinModTime), exportTime(entry.MaxModTime),
+			strconv.Itoa(exceptions)}
+	}
+
+	outf.Write(exportColumns)
+	for _, entry := range report.Entries {
+		outf.Write(row(report.Root, entry.Group, entry.Label, entry, 0))
+	}
+	for _, rs := range report.Roots {
+		total := ExportEntry{TotalBytes: rs.Total, Allocated: rs.Allocated, FileCount: rs.Files, LineCount: rs.Lines}
+		outf.Write(row(rs.Root, "", "(total)", total, rs.ExceptionCount))
+	}
+	total := ExportEntry{TotalBytes: report.TotalBytes, Allocated: report.Allocated, FileCount: report.FileCount, LineCount: report.LineCount,
+		CodeLines: report.CodeLines, CommentLines: report.CommentLines, BlankLines: report.BlankLines,
+		MinModTime: report.MinModTime, MaxModTime: report.MaxModTime}
+	outf.Write(row(report.Root, "", "(total)", total, report.ExceptionCount))
+
+	outf.Flush()
+	return outf.Error()
+}
+
+// exportCellField names the entry field a cross tab cell holds in the wide layout, as --cell and --size pick.
+func exportCellField(opts *ProgramOpts) string {
+	switch opts.CellKind() {
+	case CellFiles:
+		return "file_count"
+	case CellLines:
+		return "line_count"
+	}
+	if opts.Size == SizeAllocated {
+		return "allocated_bytes"
+	}
+	return "total_bytes"
+}
+
+// writeWide writes a cross tab as a matrix: a row per label of the first --by key, a column per label of the second
+// and a total of each, the cells holding the report's Cell field. Only cross tabs have a wide layout.
+func writeWide(w io.Writer, comma rune, report ExportReport) error {
+	pt := report.pivot
+	if pt == nil {
+		return fmt.Errorf("the wide layout is for --by with two keys, such as --by ext,month")
+	}
+
+	outf := csv.NewWriter(w)
+	outf.Comma = comma
+	header := append([]string{pt.RowKey}, pt.Cols...)
+	outf.Write(append(header, "(total)"))
+	line := func(label string, value func(col string) uint64, total uint64) []string {
+		row := []string{label}
+		for _, col := range pt.Cols {
+			row = append(row, strconv.FormatUint(value(col), 10))
+		}
+		return append(row, strconv.FormatUint(total, 10))
+	}
+	for _, row := range pt.Rows {
+		row := row
+		outf.Write(line(row, func(col string) uint64 { return pt.Cell(row, col) }, pt.RowTotals[row]))
+	}
+	outf.Write(line("(total)", func(col string) uint64 { return pt.ColTotals[col] }, pt.Total))
+
+	outf.Flush()
+	return outf.Error()
+}
+
+// writeYAML writes the report as a YAML document. The layout is simple enough not to need a YAML library.
+func writeYAML(w io.Writer, report ExportReport) error {
+	outf := bufio.NewWriter(w)
+	fmt.Fprintf(outf, "root: %s\n", yamlString(report.Root))
+	if len(report.Roots) > 0 {
+		fmt.Fprintln(outf, "roots:")
+		for _, rs := range report.Roots {
+			fmt.Fprintf(outf, "  - root: %s\n", yamlString(rs.Root))
+			fmt.Fprintf(outf, "    total_bytes: %d\n", rs.Total)
+			fmt.Fprintf(outf, "    allocated_bytes: %d\n", rs.Allocated)
+			fmt.Fprintf(outf, "    file_count: %d\n", rs.Files)
+			fmt.Fprintf(outf, "    line_count: %d\n", rs.Lines)
+			fmt.Fprintf(outf, "    exception_count: %d\n", rs.ExceptionCount)
+		}
+	}
+	fmt.Fprintf(outf, "mode: %s\n", yamlString(report.Mode))
+	fmt.Fprintf(outf, "as_of: %s\n", exportTime(report.AsOf))
+	fmt.Fprintf(outf, "time_field: %s\n", report.TimeField)
+	if len(report.Keys) > 0 {
+		fmt.Fprintf(outf, "keys: [%s]\n", strings.Join(report.Keys, ", "))
+		fmt.Fprintf(outf, "cell: %s\n", report.Cell)
+	}
+	fmt.Fprintf(outf, "total_bytes: %d\n", report.TotalBytes)
+	fmt.Fprintf(outf, "allocated_bytes: %d\n", report.Allocated)
+	fmt.Fprintf(outf, "file_count: %d\n", report.FileCount)
+	fmt.Fprintf(outf, "line_count: %d\n", report.LineCount)
+	fmt.Fprintf(outf, "code_lines: %d\n", report.CodeLines)
+	fmt.Fprintf(outf, "comment_lines: %d\n", report.CommentLines)
+	fmt.Fprintf(outf, "blank_lines: %d\n", report.BlankLines)
+	fmt.Fprintf(outf, "exception_count: %d\n", report.ExceptionCount)
+	fmt.Fprintf(outf, "hard_links: %d\n", report.HardLinks)
+	if len(report.Skipped) > 0 {
+		fmt.Fprintln(outf, "skipped:")
+		for _, kind := range []string{SkipMount, SkipSymlink, SkipLoop} {
+			if count, ok := report.Skipped[kind]; ok {
+				fmt.Fprintf(outf, "  %s: %d\n", kind, count)
+			}
+		}
+	}
+	fmt.Fprintf(outf, "min_mtime: %s\n", exportTime(report.MinModTime))
+	fmt.Fprintf(outf, "max_mtime: %s\n", exportTime(report.MaxModTime))
+	writeYAMLTop(outf, "", report.Top)
+	if len(report.Entries) == 0 {
+		fmt.Fprintln(outf, "entries: []")
+	} else {
+		fmt.Fprintln(outf, "entries:")
+	}
+	for _, entry := range report.Entries {
+		fmt.Fprintf(outf, "  - label: %s\n", yamlString(entry.Label))
+		fmt.Fprintf(outf, "    group: %s\n", yamlString(entry.Group))
+		fmt.Fprintf(outf, "    total_bytes: %d\n", entry.TotalBytes)
+		fmt.Fprintf(outf, "    allocated_bytes: %d\n", entry.Allocated)
+		fmt.Fprintf(outf, "    file_count: %d\n", entry.FileCount)
+		fmt.Fprintf(outf, "    line_count: %d\n", entry.LineCount)
+		fmt.Fprintf(outf, "    code_lines: %d\n", entry.CodeLines)
+		fmt.Fprintf(outf, "    comment_lines: %d\n", entry.CommentLines)
+		fmt.Fprintf(outf, "    blank_lines: %d\n", entry.BlankLines)
+		fmt.Fprintf(outf, "    min_mtime: %s\n", exportTime(entry.MinModTime))
+		fmt.Fprintf(outf, "    max_mtime: %s\n", exportTime(entry.MaxModTime))
+		writeYAMLTop(outf, "    ", entry.Top)
+	}
+	return outf.Flush()
+}
+
+// writeYAMLTop writes the top files as a "top" mapping indented by indent. Nothing is written when none were kept.
+func writeYAMLTop(outf *bufio.Writer, indent string, top *ExportTop) {
+	if top == nil {
+		return
+	}
+	fmt.Fprintf(outf, "%stop:\n", indent)
+	fmt.Fprintf(outf, "%s  time_field: %s\n", indent, top.TimeField)
+	lists := []struct {
+		name  string
+		files []FileStat
+	}{{"largest", top.Largest}, {"newest", top.Newest}, {"oldest", top.Oldest}}
+	for _, list := range lists {
+		if len(list.files) == 0 {
+			fmt.Fprintf(outf, "%s  %s: []\n", indent, list.name)
+			continue
+		}
+		fmt.Fprintf(outf, "%s  %s:\n", indent, list.name)
+		for _, file := range list.files {
+			fmt.Fprintf(outf, "%s    - path: %s\n", indent, yamlString(file.Path))
+			fmt.Fprintf(outf, "%s      size: %d\n", indent, file.Size)
+			fmt.Fprintf(outf, "%s      allocated: %d\n", indent, file.Allocated)
+			fmt.Fprintf(outf, "%s      time: %s\n", indent, exportTime(file.Time))
+		}
+	}
+}
+
+// writeMarkdown writes the scan totals as a list followed by a table of the entries.
+func writeMarkdown(w io.Writer, report ExportReport) error {
+	outf := bufio.NewWriter(w)
+	fmt.Fprintf(outf, "# File summary of %s\n\n", markdownCell(report.Root))
+	fmt.Fprintf(outf, "- as of: %s\n", exportTime(report.AsOf))
+	fmt.Fprintf(outf, "- total bytes: %d (%s)\n", report.TotalBytes, humansize(report.TotalBytes))
+	fmt.Fprintf(outf, "- allocated bytes: %d (%s)\n", report.Allocated, humansize(report.Allocated))
+	fmt.Fprintf(outf, "- hard links counted once: %d\n", report.HardLinks)
+	if len(report.Skipped) > 0 {
+		fmt.Fprintf(outf, "- skipped: %s\n", formatCounts(report.Skipped))
+	}
+	fmt.Fprintf(outf, "- files: %d\n", report.FileCount)
+	fmt.Fprintf(outf, "- lines: %d (code %d, comment %d, blank %d)\n", report.LineCount, report.CodeLines,
+		report.CommentLines, report.BlankLines)
+	fmt.Fprintf(outf, "- exceptions: %d\n", report.ExceptionCount)
+	fmt.Fprintf(outf, "- %s: %s to %s\n\n", timeFieldVerbs[report.TimeField], exportTime(report.MinModTime),
+		exportTime(report.MaxModTime))
+
+	if len(report.Roots) > 0 {
+		fmt.Fprintln(outf, "| root | total_bytes | allocated_bytes | file_count | line_count | exception_count |")
+		fmt.Fprintln(outf, "|---|--:|--:|--:|--:|--:|")
+		for _, rs := range report.Roots {
+			fmt.Fprintf(outf, "| %s | %d | %d | %d | %d | %d |\n", markdownCell(rs.Root), rs.Total, rs.Allocated, rs.Files,
+				rs.Lines, rs.ExceptionCount)
+		}
+		fmt.Fprintln(outf)
+	}
+
+	fmt.Fprintln(outf, "| group | label | total_bytes | allocated_bytes | file_count | line_count | code_lines | comment_lines | blank_lines | min_mtime | max_mtime |")
+	fmt.Fprintln(outf, "|---|---|--:|--:|--:|--:|--:|--:|--:|---|---|")
+	for _, entry := range report.Entries {
+		fmt.Fprintf(outf, "| %s | %s | %d | %d | %d | %d | %d | %d | %d | %s | %s |\n", markdownCell(entry.Group),
+			markdownCell(entry.Label), entry.TotalBytes, entry.Allocated, entry.FileCount, entry.LineCount, entry.CodeLines,
+			entry.CommentLines, entry.BlankLines, exportTime(entry.MinModTime), exportTime(entry.MaxModTime))
+	}
+
+	if report.Top != nil {
+		lists := []struct {
+			title string
+			files []FileStat
+		}{{"Largest files", report.Top.Largest}, {"Newest files", report.Top.Newest}, {"Oldest files", report.Top.Oldest}}
+		for _, list := range lists {
+			fmt.Fprintf(outf, "\n## %s\n\n", list.title)
+			fmt.Fprintf(outf, "| path | size | allocated | %s |\n", report.Top.TimeField)
+			fmt.Fprintln(outf, "|---|--:|--:|---|")
+			for _, file := range list.files {
+				fmt.Fprintf(outf, "| %s | %d | %d | %s |\n", markdownCell(file.Path), file.Size, file.Allocated,
+					exportTime(file.Time))
+			}
+		}
+	}
+	return outf.Flush()
+}
+
+// exportTime formats a time for export, an unset time is left empty.
+func exportTime(t time.Time) string {
+	if t.IsZero() {
+		return ""
+	}
+	return t.Format(time.RFC3339)
+}
+
+// yamlString quotes a string for YAML. JSON string syntax is valid YAML.
+func yamlString(s string) string {
+	quoted, _ := json.Marshal(s)
+	return string(quoted)
+}
+
+// markdownCell escapes the characters that would break a markdown table.
+func markdownCell(s string) string {
+	return strings.NewReplacer("|", "\\|", "\n", " ").Replace(s)
+}
@@ -0,0 +1,121 @@
+// core package contains all the components needed by the summarizefiles utility.
+package core
+
+import (
+	"path/filepath"
+	"strings"
+)
+
+const (
+	// ExtNone, ExtDotfile and ExtLong : the labels of files without a usable extension: none at all, a hidden file
+	// whose name is all there is, and an extension too long to be a file type.
+	ExtNone    = "(no ext)"
+	ExtDotfile = "(dotfile)"
+	ExtLong    = "(long ext)"
+	// ExtBackup : the label backup and temporary files are grouped under with --backups group.
+	ExtBackup = "(backup)"
+
+	ExtCaseLower = "lower"
+	ExtCaseKeep  = "keep"
+
+	BackupsGroup = "group"
+	BackupsStrip = "strip"
+	BackupsKeep  = "keep"
+)
+
+// maxExtLen : extensions longer than this are more likely part of a name (a hash, a date) than a file type.
+const maxExtLen = 9
+
+// compoundExtensions : extensions made of two parts that name one file type, matched before the last part alone.
+var compoundExtensions = []string{
+	"tar.gz", "tar.bz2", "tar.xz", "tar.zst", "tar.lz", "tar.lz4", "tar.lzma", "tar.z",
+	"d.ts", "d.mts", "d.cts",
+}
+
+// knownNames : files known by their whole name, summarized under it with --known-names rather than as ExtNone or
+// ExtDotfile. Keyed by the lower case name.
+var knownNames = map[string]string{}
+
+func init() {
+	for _, name := range []string{
+		"Makefile", "GNUmakefile", "Dockerfile", "Containerfile", "Jenkinsfile", "Vagrantfile", "Procfile",
+		"Gemfile", "Rakefile", "Pipfile", "Brewfile", "LICENSE", "LICENCE", "COPYING", "NOTICE", "README",
+		"CHANGELOG", "CHANGES", "NEWS", "AUTHORS", "CONTRIBUTORS", "MAINTAINERS", "CODEOWNERS", "INSTALL",
+		"TODO", "VERSION", ".gitignore", ".gitattributes", ".gitmodules", ".editorconfig", ".dockerignore",
+		".npmrc", ".env",
+	} {
+		knownNames[strings.ToLower(name)] = name
+	}
+}
+
+// backupExtensions : the extensions editors, patch and package managers give backup and temporary copies.
+var backupExtensions = map[string]bool{
+	"bak": true, "orig": true, "rej": true, "swp": true, "swo": true, "tmp": true, "old": true,
+	"dpkg-old": true, "dpkg-dist": true, "rpmsave": true, "rpmnew": true,
+}
+
+// ExtensionOf works out the extension a file is summarized under from its base name, so dots in directory names
+// don't matter. --ext-case lower folds JPG into jpg. Known compound extensions such as tar.gz are kept whole.
+// Backup copies (foo.go~, foo.go.orig, .foo.go.swp) are grouped under ExtBackup, stripped back to the file they
+// copy, or kept as they are, as --backups says. Files without a usable extension are ExtNone, ExtDotfile or ExtLong,
+// or with --known-names their own name when it's a well known one such as Makefile or LICENSE.
+func ExtensionOf(opts *ProgramOpts, path string) string {
+	name := filepath.Base(path)
+	if opts.ExtCase != ExtCaseKeep {
+		name = strings.ToLower(name)
+	}
+
+	if opts.Backups != BackupsKeep {
+		stripped, backup := stripBackup(name)
+		if backup && opts.Backups != BackupsStrip {
+			return ExtBackup
+		}
+		name = stripped
+	}
+	if opts.KnownNames {
+		if known, ok := knownNames[strings.ToLower(name)]; ok {
+			return known
+		}
+	}
+
+	// a leading dot hides a file, it doesn't start an extension
+	hidden := strings.HasPrefix(name, ".")
+	name = strings.TrimLeft(name, ".")
+	lower := strings.ToLower(name)
+	for _, compound := range compoundExtensions {
+		if len(lower) > len(compound)+1 && strings.HasSuffix(lower, "."+compound) {
+			return name[len(name)-len(compound):]
+		}
+	}
+
+	dot := strings.LastIndex(name, ".")
+	switch {
+	case dot < 0 && hidden:
+		return ExtDotfile
+	case dot < 0 || dot == len(name)-1:
+		return ExtNone
+	case len(name)-dot-1 > maxExtLen:
+		return ExtLong
+	}
+	return name[dot+1:]
+}
+
+// stripBackup removes a backup or temporary suffix from a file name, reporting whether there was one. Vim swap files
+// also lose the dot that hides them.
+func stripBackup(name string) (string, bool) {
+	if strings.HasSuffix(name, "~") {
+		return strings.TrimRight(name, "~"), true
+	}
+	dot := strings.LastIndex(name, ".")
+	if dot <= 0 {
+		return name, false
+	}
+	ext := strings.ToLower(name[dot+1:])
+	if !backupExtensions[ext] {
+		return name, false
+	}
+	if ext == "swp" || ext == "swo" {
+		return strings.TrimPrefix(name[:dot], "."), true
+	}
+	return name[:dot], true
+}
@@ -0,0 +1,224 @@
+// core package contains all the components needed by the summarizefiles utility.
+package core
+
+import (
+	"bufio"
+	"fmt"
+	"os"
+	"path"
+	"regexp"
+	"strings"
+)
+
+// RegexPrefix : patterns starting with this are regular expressions rather than globs.
+const RegexPrefix = "re:"
+
+// Pattern type is a compiled --exclude / --include pattern. Patterns are matched against slash separated paths
+// relative to the scan root. A glob without a slash matches the base name at any depth.
+type Pattern struct {
+	Text     string
+	re       *regexp.Regexp
+	basename bool
+}
+
+// CompilePattern compiles a doublestar glob, or a regular expression when prefixed with "re:".
+func CompilePattern(text string) (*Pattern, error) {
+	p := &Pattern{Text: text}
+
+	if strings.HasPrefix(text, RegexPrefix) {
+		re, err := regexp.Compile(strings.TrimPrefix(text, RegexPrefix))
+		if err != nil {
+			return nil, fmt.Errorf("bad pattern %q: %w", text, err)
+		}
+		p.re = re
+		return p, nil
+	}
+
+	glob := strings.TrimPrefix(text, "./")
+	glob = strings.TrimSuffix(glob, "/")
+	p.basename = !strings.Contains(glob, "/")
+	glob = strings.TrimPrefix(glob, "/")
+	expr, err := GlobToRegexp(glob)
+	if err != nil {
+		return nil, fmt.Errorf("bad pattern %q: %w", text, err)
+	}
+	p.re, err = regexp.Compile(expr)
+	if err != nil {
+		return nil, fmt.Errorf("bad pattern %q: %w", text, err)
+	}
+	return p, nil
+}
+
+// Match reports whether a path relative to the scan root matches the pattern.
+func (p *Pattern) Match(rel string) bool {
+	if p.basename {
+		return p.re.MatchString(path.Base(rel))
+	}
+	return p.re.MatchString(rel)
+}
+
+// GlobToRegexp translates a doublestar glob into an anchored regular expression. '*' and '?' stop at a slash, '**'
+// crosses any number of directories, '[...]' is a character class ('[!...]' negated) and '{a,b}' an alternation.
+func GlobToRegexp(glob string) (string, error) {
+	var sb strings.Builder
+	sb.WriteString("^")
+
+	braces := 0
+	for idx := 0; idx < len(glob); idx++ {
+		c := glob[idx]
+		switch c {
+		case '*':
+			if idx+1 < len(glob) && glob[idx+1] == '*' {
+				atStart := idx == 0 || glob[idx-1] == '/'
+				if atStart && idx+2 < len(glob) && glob[idx+2] == '/' {
+					// "**/" matches zero or more whole directories
+					sb.WriteString("(?:.*/)?")
+					idx += 2
+				} else if atStart && idx+2 == len(glob) && idx > 0 {
+					// a trailing "/**" also matches the directory itself
+					str := sb.String()
+					sb.Reset()
+					sb.WriteString(strings.TrimSuffix(str, "/"))
+					sb.WriteString("(?:/.*)?")
+					idx++
+				} else {
+					sb.WriteString(".*")
+					idx++
+				}
+			} else {
+				sb.WriteString("[^/]*")
+			}
+		case '?':
+			sb.WriteString("[^/]")
+		case '[':
+			end := strings.IndexByte(glob[idx+1:], ']')
+			if end < 0 {
+				return "", fmt.Errorf("unterminated character class")
+			}
+			class := glob[idx+1 : idx+1+end]
+			if strings.HasPrefix(class, "!") {
+				class = "^" + class[1:]
+			}
+			sb.WriteString("[" + class + "]")
+			idx += end + 1
+		case '{':
+			braces++
+			sb.WriteString("(?:")
+		case '}':
+			if braces == 0 {
+				sb.WriteString(regexp.QuoteMeta("}"))
+			} else {
+				braces--
+				sb.WriteString(")")
+			}
+		case ',':
+			if braces > 0 {
+				sb.WriteString("|")
+			} else {
+				sb.WriteString(",")
+			}
+		case '\\':
+			if idx+1 < len(glob) {
+				idx++
+				sb.WriteString(regexp.QuoteMeta(string(glob[idx])))
+			}
+		default:
+			sb.WriteString(regexp.QuoteMeta(string(c)))
+		}
+	}
+	if braces > 0 {
+		return "", fmt.Errorf("unterminated alternation")
+	}
+
+	sb.WriteString("$")
+	return sb.String(), nil
+}
+
+// Filter type decides which paths a scan skips.
+type Filter struct {
+	Excludes         []*Pattern
+	Includes         []*Pattern
+	RespectGitignore bool
+}
+
+// NewFilter compiles the filter the program options describe. Returns nil when nothing is filtered.
+func NewFilter(opts *ProgramOpts) (*Filter, error) {
+	excludes := opts.Excludes
+	if opts.ExcludeFrom != "" {
+		more, err := ReadPatternFile(opts.ExcludeFrom)
+		if err != nil {
+			return nil, err
+		}
+		excludes = append(append([]string{}, excludes...), more...)
+	}
+
+	if len(excludes) == 0 && len(opts.Includes) == 0 && !opts.RespectGitignore {
+		return nil, nil
+	}
+
+	f := &Filter{RespectGitignore: opts.RespectGitignore}
+	for _, text := range excludes {
+		p, err := CompilePattern(text)
+		if err != nil {
+			return nil, err
+		}
+		f.Excludes = append(f.Excludes, p)
+	}
+	for _, text := range opts.Includes {
+		p, err := CompilePattern(text)
+		if err != nil {
+			return nil, err
+		}
+		f.Includes = append(f.Includes, p)
+	}
+	return f, nil
+}
+
+// ReadPatternFile reads one pattern per line, skipping blank lines and # comments.
+func ReadPatternFile(path string) ([]string, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	var patterns []string
+	inf := bufio.NewScanner(f)
+	for inf.Scan() {
+		line := strings.TrimSpace(inf.Text())
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+		patterns = append(patterns, line)
+	}
+	return patterns, inf.Err()
+}
+
+// Skip reports whether a path relative to the scan root should be left out. Excluded directories are pruned with
+// everything below them. Includes only apply to files, a directory may hold included files whatever its name.
+func (f *Filter) Skip(rel string, isDir bool, ignore *IgnoreList) bool {
+	for _, p := range f.Excludes {
+		if p.Match(rel) {
+			return true
+		}
+	}
+
+	if f.RespectGitignore {
+		if isDir && path.Base(rel) == ".git" {
+			return true
+		}
+		if ignore.Ignored(rel, isDir) {
+			return true
+		}
+	}
+
+	if !isDir && len(f.Includes) > 0 {
+		for _, p := range f.Includes {
+			if p.Match(rel) {
+				return false
+			}
+		}
+		return true
+	}
+	return false
+}
@@ -0,0 +1,120 @@
+// core package contains all the components needed by the summarizefiles utility.
+package core
+
+import (
+	"os"
+	"path"
+	"path/filepath"
+	"regexp"
+	"strings"
+)
+
+// IgnoreFileNames : the per directory ignore files honored by --respect-gitignore.
+var IgnoreFileNames = []string{".gitignore", ".ignore"}
+
+// ignoreRule type is one line of an ignore file.
+type ignoreRule struct {
+	re       *regexp.Regexp
+	negate   bool
+	dirOnly  bool
+	basename bool
+}
+
+// IgnoreList type holds the rules of the ignore files in one directory, chained to the rules of its parent
+// directories. Rules in deeper directories take precedence.
+type IgnoreList struct {
+	parent *IgnoreList
+	// base is the directory holding the ignore files, relative to the scan root
+	base  string
+	rules []ignoreRule
+}
+
+// LoadIgnoreFiles reads the ignore files in dir, whose path relative to the scan root is base. Returns parent when
+// dir has no rules of its own.
+func LoadIgnoreFiles(dir string, base string, parent *IgnoreList) *IgnoreList {
+	var rules []ignoreRule
+	for _, name := range IgnoreFileNames {
+		lines, err := os.ReadFile(filepath.Join(dir, name))
+		if err != nil {
+			continue
+		}
+		for _, line := range strings.Split(string(lines), "\n") {
+			rule, ok := parseIgnoreLine(line)
+			if ok {
+				rules = append(rules, rule)
+			}
+		}
+	}
+
+	if len(rules) == 0 {
+		return parent
+	}
+	return &IgnoreList{parent: parent, base: base, rules: rules}
+}
+
+// parseIgnoreLine parses a line of an ignore file following the .gitignore rules.
+func parseIgnoreLine(line string) (ignoreRule, bool) {
+	rule := ignoreRule{}
+
+	line = strings.TrimRight(line, "\r")
+	// trailing spaces are ignored unless escaped
+	for strings.HasSuffix(line, " ") && !strings.HasSuffix(line, "\\ ") {
+		line = line[:len(line)-1]
+	}
+	if line == "" || strings.HasPrefix(line, "#") {
+		return rule, false
+	}
+
+	if strings.HasPrefix(line, "!") {
+		rule.negate = true
+		line = line[1:]
+	} else if strings.HasPrefix(line, "\\!") || strings.HasPrefix(line, "\\#") {
+		line = line[1:]
+	}
+	if strings.HasSuffix(line, "/") {
+		rule.dirOnly = true
+		line = strings.TrimSuffix(line, "/")
+	}
+	// a slash anywhere but the end anchors the pattern to the directory of the ignore file
+	rule.basename = !strings.Contains(line, "/")
+	line = strings.TrimPrefix(line, "/")
+	if line == "" {
+		return rule, false
+	}
+
+	expr, err := GlobToRegexp(line)
+	if err != nil {
+		return rule, false
+	}
+	rule.re, err = regexp.Compile(expr)
+	if err != nil {
+		return rule, false
+	}
+	return rule, true
+}
+
+// Ignored reports whether a path relative to the scan root is ignored. The last matching rule of the deepest ignore
+// file with an opinion decides.
+func (il *IgnoreList) Ignored(rel string, isDir bool) bool {
+	for list := il; list != nil; list = list.parent {
+		local := rel
+		if list.base != "" {
+			local = strings.TrimPrefix(rel, list.base+"/")
+		}
+
+		for idx := len(list.rules) - 1; idx >= 0; idx-- {
+			rule := list.rules[idx]
+			if rule.dirOnly && !isDir {
+				continue
+			}
+			subject := local
+			if rule.basename {
+				subject = path.Base(local)
+			}
+			if rule.re.MatchString(subject) {
+				return !rule.negate
+			}
+		}
+	}
+	return false
+}
@@ -0,0 +1,216 @@
+// core package contains all the components needed by the summarizefiles utility.
+package core
+
+import (
+	"fmt"
+	"os"
+	"strings"
+	"time"
+)
+
+const (
+	KeyOwner = "owner"
+	KeyGroup = "group"
+	KeyMode  = "mode"
+	KeySize  = "size"
+	KeyExt   = "ext"
+	KeyLang  = "lang"
+	// KeyTime labels files as --time does, the calendar keys by the period of --time-field they fall in.
+	KeyTime    = "time"
+	KeyHour    = GranHour
+	KeyDay     = GranDay
+	KeyWeek    = GranWeek
+	KeyMonth   = GranMonth
+	KeyQuarter = GranQuarter
+	KeyYear    = GranYear
+)
+
+// GroupKeys : what --by can summarize files by, one key or two separated by a comma for a cross tab.
+var GroupKeys = []string{KeyOwner, KeyGroup, KeyMode, KeySize, KeyExt, KeyLang, KeyTime, KeyHour, KeyDay, KeyWeek,
+	KeyMonth, KeyQuarter, KeyYear}
+
+// maxGroupKeys : how many keys --by crosses, rows and columns.
+const maxGroupKeys = 2
+
+const (
+	ModeSetuid        = "setuid"
+	ModeSetgid        = "setgid"
+	ModeWorldWritable = "world-writable"
+	ModeExecutable    = "executable"
+	ModePlain         = "plain"
+	ModeSpecial       = "special"
+)
+
+// sizeClasses : the upper bounds of the --by size classes, log scale so a tree of millions of tiny files and one of a
+// few huge ones look nothing alike. Sizes from the last bound up are in the final class.
+var sizeClasses = []struct {
+	below uint64
+	label string
+}{
+	{1, "0 B"},
+	{1 << 10, "<1K"},
+	{4 << 10, "<4K"},
+	{64 << 10, "<64K"},
+	{1 << 20, "<1M"},
+	{16 << 20, "<16M"},
+	{256 << 20, "<256M"},
+	{1 << 30, "<1G"},
+}
+
+// sizeClassTop : the label of the files from the last of the sizeClasses up.
+const sizeClassTop = ">=1G"
+
+// ValidGroupKey reports whether key is one of the GroupKeys.
+func ValidGroupKey(key string) bool {
+	for _, name := range GroupKeys {
+		if key == name {
+			return true
+		}
+	}
+	return false
+}
+
+// ParseGroupKeys checks a --by spec of one key, or two separated by a comma, and returns the keys.
+func ParseGroupKeys(spec string) ([]string, error) {
+	keys := strings.Split(spec, ",")
+	if len(keys) > maxGroupKeys {
+		return nil, fmt.Errorf("--by %s crosses %d keys, at most %d can be", spec, len(keys), maxGroupKeys)
+	}
+	for idx, key := range keys {
+		keys[idx] = strings.TrimSpace(key)
+		if !ValidGroupKey(keys[idx]) {
+			return nil, fmt.Errorf("unknown --by %s, expected %s", keys[idx], strings.Join(GroupKeys, ", "))
+		}
+	}
+	if len(keys) == maxGroupKeys && keys[0] == keys[1] {
+		return nil, fmt.Errorf("--by %s crosses %s with itself", spec, keys[0])
+	}
+	return keys, nil
+}
+
+// ByKeys returns the --by keys, none when summarizing by the other options.
+func (opts *ProgramOpts) ByKeys() []string {
+	if opts.By == "" {
+		return nil
+	}
+	keys := strings.Split(opts.By, ",")
+	for idx := range keys {
+		keys[idx] = strings.TrimSpace(keys[idx])
+	}
+	return keys
+}
+
+// UsesKey reports whether the --by grouping summarizes files by key, alone or crossed with another.
+func (opts *ProgramOpts) UsesKey(key string) bool {
+	for _, used := range opts.ByKeys() {
+		if used == key {
+			return true
+		}
+	}
+	return false
+}
+
+// UsesTime reports whether files are grouped by time, with --time or a time --by key, so the buckets and the time
+// zone they're worked out in are needed.
+func (opts *ProgramOpts) UsesTime() bool {
+	for _, key := range opts.ByKeys() {
+		if key != KeySize && keyOrdered(key) {
+			return true
+		}
+	}
+	return opts.Time
+}
+
+// Pivot reports whether --by crosses two keys, the files are then summarized in a table of the first key's labels
+// by the second's.
+func (opts *ProgramOpts) Pivot() bool {
+	return len(opts.ByKeys()) == maxGroupKeys
+}
+
+// KeyLabel returns the label a file is summarized under for a --by key.
+func KeyLabel(opts *ProgramOpts, key string, rec *FileRecord) string {
+	switch key {
+	case KeyOwner:
+		return rec.Owner
+	case KeyGroup:
+		return rec.OwnerGroup
+	case KeyMode:
+		return ModeClass(rec.Info.Mode())
+	case KeySize:
+		_, label := SizeClass(uint64(rec.Info.Size()))
+		return label
+	case KeyExt:
+		return ExtensionOf(opts, rec.Path)
+	case KeyLang:
+		return rec.Language
+	case KeyTime:
+		return opts.GetTimeBuckets().Label(rec.When(), opts.Now())
+	case KeyHour, KeyDay, KeyWeek, KeyMonth, KeyQuarter, KeyYear:
+		loc := time.Local
+		if opts.TimeBuckets != nil {
+			loc = opts.TimeBuckets.Location
+		}
+		return FormatTimeLabel(rec.When().In(loc), key)
+	}
+	return ""
+}
+
+// keyOrdered reports whether a key's labels have an order of their own, sizes and times, rather than being listed
+// largest first.
+func keyOrdered(key string) bool {
+	switch key {
+	case KeySize, KeyTime, KeyHour, KeyDay, KeyWeek, KeyMonth, KeyQuarter, KeyYear:
+		return true
+	}
+	return false
+}
+
+// keyLess orders two labels of an ordered key: size classes smallest first, calendar periods oldest first.
+func keyLess(key string, a string, b string) bool {
+	if key == KeySize {
+		return sizeClassIndex(a) < sizeClassIndex(b)
+	}
+	return a < b
+}
+
+// SizeClass sorts a file size into its --by size class. The group is the class label prefixed by its position, so
+// groups sort smallest first.
+func SizeClass(size uint64) (string, string) {
+	for idx, class := range sizeClasses {
+		if size < class.below {
+			return fmt.Sprintf("%02d%s", idx+1, class.label), class.label
+		}
+	}
+	return fmt.Sprintf("%02d%s", len(sizeClasses)+1, sizeClassTop), sizeClassTop
+}
+
+// sizeClassIndex returns the position of a size class label, after every class for labels that aren't one.
+func sizeClassIndex(label string) int {
+	for idx, class := range sizeClasses {
+		if label == class.label {
+			return idx
+		}
+	}
+	if label == sizeClassTop {
+		return len(sizeClasses)
+	}
+	return len(sizeClasses) + 1
+}
+
+// ModeClass sorts a file into the permission class that matters most when auditing a tree: setuid, setgid,
+// world-writable, executable by anyone, or plain. Fifos, sockets and devices are special.
+func ModeClass(mode os.FileMode) string {
+	switch {
+	case !mode.IsRegular():
+		return ModeSpecial
+	case mode&os.ModeSetuid != 0:
+		return ModeSetuid
+	case mode&os.ModeSetgid != 0:
+		return ModeSetgid
+	case mode.Perm()&0o002 != 0:
+		return ModeWorldWritable
+	case mode.Perm()&0o111 != 0:
+		return ModeExecutable
+	}
+	return ModePlain
+}
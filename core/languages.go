@@ -0,0 +1,154 @@
+// core package contains all the components needed by the summarizefiles utility.
+package core
+
+import (
+	"bufio"
+	"bytes"
+	_ "embed"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// LangOther : the language of files the table doesn't know.
+const LangOther = "Other"
+
+// shebangLen : how much of a file is read looking for its shebang line.
+const shebangLen = 256
+
+//go:embed languages.txt
+var languageTable string
+
+// LanguageMap type maps files to the language they are written in by file name, extension, then the interpreter
+// named by a script's shebang line.
+type LanguageMap struct {
+	names        map[string]string
+	extensions   map[string]string
+	interpreters map[string]string
+}
+
+// NewLanguageMap construct a LanguageMap instance from the built in table, overridden by the --lang-map file when
+// the options name one.
+func NewLanguageMap(opts *ProgramOpts) (*LanguageMap, error) {
+	lm := &LanguageMap{
+		names:        make(map[string]string),
+		extensions:   make(map[string]string),
+		interpreters: make(map[string]string),
+	}
+	if err := lm.Load(strings.NewReader(languageTable), "built in table"); err != nil {
+		return nil, err
+	}
+	if opts.LangMap != "" {
+		inf, err := os.Open(opts.LangMap)
+		if err != nil {
+			return nil, err
+		}
+		defer inf.Close()
+		if err := lm.Load(inf, opts.LangMap); err != nil {
+			return nil, err
+		}
+	}
+	return lm, nil
+}
+
+// Load reads "Language: .ext name #!interpreter" lines into the map, replacing what was there for the same
+// extensions, names and interpreters. Blank lines and lines starting with # are skipped.
+func (lm *LanguageMap) Load(r io.Reader, source string) error {
+	scanner := bufio.NewScanner(r)
+	lineno := 0
+	for scanner.Scan() {
+		lineno++
+		line := strings.TrimSpace(scanner.Text())
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+		colon := strings.Index(line, ":")
+		if colon < 1 {
+			return fmt.Errorf("%s:%d: expected \"Language: .ext name #!interpreter\"", source, lineno)
+		}
+		lang := strings.TrimSpace(line[:colon])
+		for _, token := range strings.Fields(line[colon+1:]) {
+			token = strings.ToLower(token)
+			switch {
+			case strings.HasPrefix(token, "#!"):
+				lm.interpreters[strings.TrimPrefix(token, "#!")] = lang
+			case strings.HasPrefix(token, "."):
+				lm.extensions[strings.TrimPrefix(token, ".")] = lang
+			default:
+				lm.names[token] = lang
+			}
+		}
+	}
+	return scanner.Err()
+}
+
+// Detect works out the language of a file. The file is only read when neither its name nor its extension are known,
+// to look for a shebang line. Unknown files are LangOther.
+func (lm *LanguageMap) Detect(path string, info os.FileInfo) string {
+	name := strings.ToLower(filepath.Base(path))
+	if lang, ok := lm.names[name]; ok {
+		return lang
+	}
+	// every extension the name has, longest first: .d.ts before .ts
+	for idx := 0; idx < len(name)-1; idx++ {
+		if name[idx] != '.' {
+			continue
+		}
+		if lang, ok := lm.extensions[name[idx+1:]]; ok {
+			return lang
+		}
+	}
+
+	if info != nil && info.Mode().IsRegular() {
+		if interp := readShebang(path); interp != "" {
+			if lang, ok := lm.interpreters[interp]; ok {
+				return lang
+			}
+			// python3.11 is python
+			if lang, ok := lm.interpreters[strings.TrimRight(interp, "0123456789.")]; ok {
+				return lang
+			}
+		}
+	}
+	return LangOther
+}
+
+// readShebang returns the lower case name of the interpreter a script's "#!" line runs, going through env. Empty for
+// files that don't start with one or can't be read.
+func readShebang(path string) string {
+	inf, err := os.Open(path)
+	if err != nil {
+		return ""
+	}
+	defer inf.Close()
+
+	buf := make([]byte, shebangLen)
+	n, _ := io.ReadFull(inf, buf)
+	buf = buf[:n]
+	if !bytes.HasPrefix(buf, []byte("#!")) {
+		return ""
+	}
+	if eol := bytes.IndexByte(buf, '\n'); eol >= 0 {
+		buf = buf[:eol]
+	}
+
+	fields := strings.Fields(string(buf[2:]))
+	if len(fields) == 0 {
+		return ""
+	}
+	interp := filepath.Base(fields[0])
+	if interp == "env" {
+		// skip env's options and variable settings: #!/usr/bin/env -S FOO=1 python3 -u
+		interp = ""
+		for _, field := range fields[1:] {
+			if strings.HasPrefix(field, "-") || strings.Contains(field, "=") {
+				continue
+			}
+			interp = filepath.Base(field)
+			break
+		}
+	}
+	return strings.ToLower(interp)
+}
@@ -7,39 +7,33 @@ import (
 	"fmt"
 	"io"
 	"os"
-	"strings"
-
-	"github.com/vimeo/go-magic/magic"
 )
 
-// initialized : has libmagic been initialized yet
-var initialiazed bool = false
-
 // debug : are we still trying to work out why something isn't working?
 var debug bool = false
 
 // CountLines give a file path, decide if the file is a text file and count the number of lines in the file.
-func CountLines(summ *FileSummary, path string) (int, error) {
-	if !initialiazed {
-		magic.AddMagicDir(magic.GetDefaultDir())
+// Text detection uses the pure-Go sniffer unless the binary was built with the libmagic tag. CountLines is called
+// concurrently by the scan workers, so it must not touch the summary.
+func CountLines(path string) (int, error) {
+	mimetype, err := MimeTypeFromFile(path)
+	if err != nil {
+		return 0, err
 	}
-
-	mimetype := magic.MimeFromFile(path)
 	//fmt.Printf("%s: %s\n", path, mimetype)
-	if strings.Contains(mimetype, "text") {
+	if IsTextType(mimetype) {
 		// TODO: count exceptions
 		inf, err := os.Open(path)
 		if err != nil {
-			summ.ExceptionCount++
 			return 0, err
 		}
+		defer inf.Close()
 
 		lines, err2 := lineCounter(inf)
 		if debug {
 			fmt.Printf("%s: %d lines\n", path, lines)
 		}
 		if err2 != nil {
-			summ.ExceptionCount++
 			return 0, err2
 		}
 		return lines, nil
@@ -63,7 +57,8 @@ func CountLines(summ *FileSummary, path string) (int, error) {
 	return 0, nil
 }
 
-// lineCounter do the core task of counting the number of lines in a text file.
+// lineCounter do the core task of counting the number of lines in a text file. Like wc -l it counts newlines, a
+// last line without one doesn't count.
 func lineCounter(r io.Reader) (int, error) {
 	// https://stackoverflow.com/questions/24562942/golang-how-do-i-determine-the-number-of-lines-in-a-file-efficiently
 	buf := make([]byte, 32*1024)
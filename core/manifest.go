@@ -0,0 +1,332 @@
+// core package contains all the components needed by the summarizefiles utility.
+package core
+
+import (
+	"bufio"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"hash"
+	"io"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+)
+
+const (
+	HashSHA256 = "sha256"
+	HashXXHash = "xxhash"
+
+	ManifestNDJSON = "ndjson"
+	ManifestSum    = "sum"
+)
+
+// ManifestEntry type is one line of a manifest: what a file looked like when it was scanned.
+type ManifestEntry struct {
+	Path      string    `json:"path"`
+	Size      int64     `json:"size"`
+	ModTime   time.Time `json:"mtime"`
+	Mode      string    `json:"mode"`
+	Hash      string    `json:"hash"`
+	Algorithm string    `json:"algorithm"`
+}
+
+// NewHash construct the hash.Hash for a hash algorithm name.
+func NewHash(algorithm string) (hash.Hash, error) {
+	switch algorithm {
+	case HashSHA256:
+		return sha256.New(), nil
+	case HashXXHash:
+		return NewXXHash64(), nil
+	}
+	return nil, fmt.Errorf("unknown hash algorithm %q, expected %s or %s", algorithm, HashSHA256, HashXXHash)
+}
+
+// HashFile hashes the content of the file at path. Returns the hash as hex.
+func HashFile(path string, algorithm string) (string, error) {
+	h, err := NewHash(algorithm)
+	if err != nil {
+		return "", err
+	}
+
+	inf, err := os.Open(path)
+	if err != nil {
+		return "", err
+	}
+	defer inf.Close()
+
+	_, err = io.Copy(h, inf)
+	if err != nil {
+		return "", err
+	}
+	return hex.EncodeToString(h.Sum(nil)), nil
+}
+
+// ManifestPath is the slash separated path of a scanned file relative to the root, the key manifests are compared by.
+func ManifestPath(root string, path string) string {
+	rel, err := filepath.Rel(root, path)
+	if err != nil || rel == "." {
+		rel = filepath.Base(path)
+	}
+	return filepath.ToSlash(rel)
+}
+
+// ManifestWriter type streams a manifest line for every file visited.
+type ManifestWriter struct {
+	Root      string
+	Format    string
+	Algorithm string
+	f         *os.File
+	outf      *bufio.Writer
+}
+
+// NewManifestWriter construct a ManifestWriter writing to path, "-" writes to stdout. Format is ndjson, or sum for
+// the "hash  path" layout sha256sum and xxhsum read.
+func NewManifestWriter(path string, root string, format string, algorithm string) (*ManifestWriter, error) {
+	if format != ManifestNDJSON && format != ManifestSum {
+		return nil, fmt.Errorf("unknown manifest format %q, expected %s or %s", format, ManifestNDJSON, ManifestSum)
+	}
+	if _, err := NewHash(algorithm); err != nil {
+		return nil, err
+	}
+
+	mw := &ManifestWriter{Root: root, Format: format, Algorithm: algorithm}
+	if path == "-" {
+		mw.f = os.Stdout
+	} else {
+		f, err := os.Create(path)
+		if err != nil {
+			return nil, err
+		}
+		mw.f = f
+	}
+	mw.outf = bufio.NewWriter(mw.f)
+	return mw, nil
+}
+
+// Write appends the manifest line for a scanned file. The record must carry its hash.
+func (mw *ManifestWriter) Write(rec *FileRecord) error {
+	entry := ManifestEntry{
+		Path:      ManifestPath(mw.Root, rec.Path),
+		Size:      rec.Info.Size(),
+		ModTime:   rec.Info.ModTime(),
+		Mode:      rec.Info.Mode().String(),
+		Hash:      rec.Hash,
+		Algorithm: mw.Algorithm,
+	}
+
+	if mw.Format == ManifestSum {
+		// sha256sum marks lines whose name had to be escaped with a leading backslash
+		name := entry.Path
+		prefix := ""
+		if strings.ContainsAny(name, "\\\n") {
+			name = strings.ReplaceAll(name, "\\", "\\\\")
+			name = strings.ReplaceAll(name, "\n", "\\n")
+			prefix = "\\"
+		}
+		_, err := fmt.Fprintf(mw.outf, "%s%s  %s\n", prefix, entry.Hash, name)
+		return err
+	}
+
+	line, err := json.Marshal(entry)
+	if err != nil {
+		return err
+	}
+	line = append(line, '\n')
+	_, err = mw.outf.Write(line)
+	return err
+}
+
+// Close flushes the manifest.
+func (mw *ManifestWriter) Close() error {
+	err := mw.outf.Flush()
+	if mw.f == os.Stdout {
+		return err
+	}
+	if err2 := mw.f.Close(); err == nil {
+		err = err2
+	}
+	return err
+}
+
+// Manifest type is a manifest loaded back from disk, keyed by relative path.
+type Manifest struct {
+	Algorithm string
+	Entries   map[string]ManifestEntry
+}
+
+// ReadManifest loads a manifest written in either format. Sum files carry no size, their entries have Size -1, and
+// the hash algorithm is inferred from the hash length.
+func ReadManifest(path string) (*Manifest, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	m := &Manifest{Entries: make(map[string]ManifestEntry)}
+	inf := bufio.NewScanner(f)
+	inf.Buffer(make([]byte, 64*1024), 1024*1024)
+	lineno := 0
+	for inf.Scan() {
+		lineno++
+		line := inf.Text()
+		if strings.TrimSpace(line) == "" {
+			continue
+		}
+
+		var entry ManifestEntry
+		if strings.HasPrefix(line, "{") {
+			err = json.Unmarshal([]byte(line), &entry)
+			if err != nil {
+				return nil, fmt.Errorf("%s:%d: %w", path, lineno, err)
+			}
+		} else {
+			entry, err = parseSumLine(line)
+			if err != nil {
+				return nil, fmt.Errorf("%s:%d: %w", path, lineno, err)
+			}
+		}
+
+		if m.Algorithm == "" {
+			m.Algorithm = entry.Algorithm
+		} else if m.Algorithm != entry.Algorithm {
+			return nil, fmt.Errorf("%s:%d: manifest mixes %s and %s hashes", path, lineno, m.Algorithm, entry.Algorithm)
+		}
+		m.Entries[entry.Path] = entry
+	}
+	if err := inf.Err(); err != nil {
+		return nil, err
+	}
+	if m.Algorithm == "" {
+		m.Algorithm = HashSHA256
+	}
+	return m, nil
+}
+
+// parseSumLine parses a "hash  path" line as written by sha256sum or xxhsum.
+func parseSumLine(line string) (ManifestEntry, error) {
+	entry := ManifestEntry{Size: -1}
+
+	escaped := strings.HasPrefix(line, "\\")
+	line = strings.TrimPrefix(line, "\\")
+	idx := strings.Index(line, " ")
+	if idx < 0 || idx+2 > len(line) {
+		return entry, fmt.Errorf("malformed checksum line")
+	}
+	entry.Hash = strings.ToLower(line[:idx])
+	// the second separator character is ' ' for text mode or '*' for binary mode
+	entry.Path = line[idx+2:]
+	if escaped {
+		entry.Path = strings.NewReplacer("\\\\", "\\", "\\n", "\n").Replace(entry.Path)
+	}
+
+	switch len(entry.Hash) {
+	case sha256.Size * 2:
+		entry.Algorithm = HashSHA256
+	case 16:
+		entry.Algorithm = HashXXHash
+	default:
+		return entry, fmt.Errorf("can't tell the hash algorithm of a %d digit hash", len(entry.Hash))
+	}
+	return entry, nil
+}
+
+const (
+	VerifyMissing = "missing"
+	VerifyExtra   = "extra"
+	VerifyResized = "resized"
+	VerifyChanged = "changed"
+	VerifyError   = "error"
+)
+
+// VerifyResult type describes a file that doesn't match its manifest entry.
+type VerifyResult struct {
+	Path     string
+	Problem  string
+	Expected ManifestEntry
+	Found    ManifestEntry
+	Message  string
+}
+
+// Verifier type checks scanned files against a manifest.
+type Verifier struct {
+	Manifest *Manifest
+	Root     string
+	Results  []VerifyResult
+	Checked  int
+	seen     map[string]bool
+}
+
+// NewVerifier construct a Verifier instance for a tree rooted at root.
+func NewVerifier(m *Manifest, root string) *Verifier {
+	return &Verifier{Manifest: m, Root: root, seen: make(map[string]bool, len(m.Entries))}
+}
+
+// Check compares a scanned file to its manifest entry. The record must carry its hash.
+func (v *Verifier) Check(rec *FileRecord) {
+	v.Checked++
+	if rec.Err != nil {
+		// already reported through Error, just don't call the file missing
+		v.seen[ManifestPath(v.Root, rec.Path)] = true
+		return
+	}
+	found := ManifestEntry{
+		Path:      ManifestPath(v.Root, rec.Path),
+		Size:      rec.Info.Size(),
+		ModTime:   rec.Info.ModTime(),
+		Mode:      rec.Info.Mode().String(),
+		Hash:      rec.Hash,
+		Algorithm: v.Manifest.Algorithm,
+	}
+
+	expected, ok := v.Manifest.Entries[found.Path]
+	if !ok {
+		v.Results = append(v.Results, VerifyResult{Path: found.Path, Problem: VerifyExtra, Found: found})
+		return
+	}
+	v.seen[found.Path] = true
+
+	if expected.Size >= 0 && expected.Size != found.Size {
+		v.Results = append(v.Results, VerifyResult{Path: found.Path, Problem: VerifyResized, Expected: expected, Found: found})
+	} else if expected.Hash != found.Hash {
+		v.Results = append(v.Results, VerifyResult{Path: found.Path, Problem: VerifyChanged, Expected: expected, Found: found})
+	}
+}
+
+// Error records a file that couldn't be checked.
+func (v *Verifier) Error(serr ScanError) {
+	path := ManifestPath(v.Root, serr.Path)
+	v.seen[path] = true
+	v.Results = append(v.Results, VerifyResult{Path: path, Problem: VerifyError, Expected: v.Manifest.Entries[path],
+		Message: serr.Op + ": " + serr.Message})
+}
+
+// Finish records the manifest entries that were never scanned as missing and sorts the results by path.
+func (v *Verifier) Finish() []VerifyResult {
+	for path, expected := range v.Manifest.Entries {
+		if !v.seen[path] {
+			v.Results = append(v.Results, VerifyResult{Path: path, Problem: VerifyMissing, Expected: expected})
+		}
+	}
+	sort.Slice(v.Results, func(i, j int) bool {
+		return v.Results[i].Path < v.Results[j].Path
+	})
+	return v.Results
+}
+
+// FormatVerifyResult formats a verify problem into a line of text.
+func FormatVerifyResult(r VerifyResult) string {
+	switch r.Problem {
+	case VerifyResized:
+		return fmt.Sprintf("%-8s %s (%d -> %d bytes)", r.Problem, r.Path, r.Expected.Size, r.Found.Size)
+	case VerifyChanged:
+		return fmt.Sprintf("%-8s %s (%s -> %s)", r.Problem, r.Path, r.Expected.Hash, r.Found.Hash)
+	case VerifyError:
+		return fmt.Sprintf("%-8s %s (%s)", r.Problem, r.Path, r.Message)
+	}
+	return fmt.Sprintf("%-8s %s", r.Problem, r.Path)
+}
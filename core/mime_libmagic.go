@@ -0,0 +1,25 @@
+//go:build libmagic
+
+// core package contains all the components needed by the summarizefiles utility.
+package core
+
+import (
+	"sync"
+
+	"github.com/vimeo/go-magic/magic"
+)
+
+// MimeBackend : which content detector the binary was built with.
+const MimeBackend = "libmagic"
+
+// magicInit : load the libmagic databases once.
+var magicInit sync.Once
+
+// MimeTypeFromFile reports the MIME type libmagic assigns to the file at path. Build with -tags libmagic to use it.
+func MimeTypeFromFile(path string) (string, error) {
+	magicInit.Do(func() {
+		magic.AddMagicDir(magic.GetDefaultDir())
+	})
+
+	return magic.MimeFromFile(path), nil
+}
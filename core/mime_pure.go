@@ -0,0 +1,12 @@
+//go:build !libmagic
+
+// core package contains all the components needed by the summarizefiles utility.
+package core
+
+// MimeBackend : which content detector the binary was built with.
+const MimeBackend = "pure-go"
+
+// MimeTypeFromFile reports a MIME-like type for the file at path using the pure-Go content sniffer.
+func MimeTypeFromFile(path string) (string, error) {
+	return SniffFile(path)
+}
@@ -3,13 +3,17 @@ package core
 
 import (
 	"fmt"
-	"os"
+	"path/filepath"
 	"sort"
+	"strings"
 	"time"
 )
 
 const (
 	DateOnly = "2006-01-02"
+
+	SizeApparent  = "apparent"
+	SizeAllocated = "allocated"
 )
 
 // ProgramOpts type is used to pass the CLI and console size parameters around to the various components.
@@ -19,41 +23,174 @@ type ProgramOpts struct {
 	Time    bool
 	Debug   bool
 	Lines   bool
+	Jobs    int
 	ConCols int
 	ConRows int
+
+	// Sloc splits the line counts into code, comment and blank lines by language, it implies Lines.
+	Sloc bool
+	// Buckets is the spec of the --time buckets, BucketsFile a file holding one, TimeZone the zone they're worked
+	// out in. TimeBuckets is the spec loaded, the default buckets when nil.
+	Buckets     string
+	BucketsFile string
+	TimeZone    string
+	TimeBuckets *TimeBuckets
+
+	// AsOf is the --as-of reference time ages are measured from, empty for now. Clock is the clock it was loaded into,
+	// the wall clock when nil.
+	AsOf  string
+	Clock Clock
+	// TimeField is the timestamp files are grouped and dated by: mtime, atime, ctime or btime.
+	TimeField string
+
+	// ExtCase, Backups and KnownNames tune how extensions are taken from file names, see ExtensionOf.
+	ExtCase    string
+	Backups    string
+	KnownNames bool
+
+	// Lang summarizes by language, detected by file name, extension and shebang. LangMap is a file overriding the
+	// built in language table.
+	Lang    bool
+	LangMap string
+
+	// By summarizes by another property of the files: their owner, group, permission class or size class, or by two
+	// of the properties crossed in a table. Cell picks what the table's cells count.
+	By   string
+	Cell string
+
+	// Manifest is where to write the per file manifest, empty for none.
+	Manifest       string
+	ManifestFormat string
+	// Hash names the content hash workers compute for every file, empty for none.
+	Hash string
+
+	// Format selects the machine readable format of the final summary, empty for none. Output is where it goes.
+	Format string
+	Output string
+
+	// Batch suppresses the live display for cron and CI, Progress then writes plain progress lines to stderr.
+	Batch    bool
+	Progress bool
+
+	// Excludes and Includes are globs or "re:" regexes matched against paths relative to the root.
+	Excludes         []string
+	Includes         []string
+	ExcludeFrom      string
+	RespectGitignore bool
+
+	// FailOnError stops the scan at the first error instead of recording it and carrying on.
+	FailOnError bool
+	ErrorsOut   string
+
+	// Dir summarizes by directory, rolling files up into every ancestor. Depth caps how deep, zero for no cap.
+	Dir   bool
+	Depth int
+
+	// Interactive runs the scan under the full screen browser instead of the live display.
+	Interactive bool
+	// Top is how many of the largest, newest and oldest files to report for the scan and each entry, zero for none.
+	Top int
+
+	// Size picks the size entries are sorted and shown by: apparent bytes, or allocated bytes on disk.
+	Size string
+
+	// OneFileSystem skips mount points below the root. FollowSymlinks scans what symlinks point at, otherwise they
+	// are skipped.
+	OneFileSystem  bool
+	FollowSymlinks bool
+}
+
+// Mode names the grouping the options select, so summaries made with different groupings can be told apart.
+func (opts *ProgramOpts) Mode() string {
+	mode := "ext"
+	if opts.By != "" {
+		mode = opts.By
+	} else if opts.Time {
+		mode = "time"
+	} else if opts.Lang {
+		mode = "lang"
+	}
+	if opts.Dir {
+		if opts.By == "" && !opts.Time && !opts.Ext && !opts.Lang {
+			return "dir"
+		}
+		return "dir/" + mode
+	}
+	return mode
+}
+
+// EntrySize is the size of an entry that --size sorts and shows by.
+func (opts *ProgramOpts) EntrySize(entry SummaryEntry) uint64 {
+	if opts.Size == SizeAllocated {
+		return entry.AllocatedBytes
+	}
+	return entry.TotalBytes
+}
+
+// SummarySize is the total size of a scan that --size shows.
+func (opts *ProgramOpts) SummarySize(summ *FileSummary) uint64 {
+	if opts.Size == SizeAllocated {
+		return summ.Allocated
+	}
+	return summ.Total
 }
 
 // SummaryEntry type represents the summary information for a group of files collesced together because of a
 //
 //	shared attribute (extension, time period modified, etc.).
 type SummaryEntry struct {
-	Group      string
-	Label      string
-	TotalBytes uint64
-	LineCount  int
-	FileCount  int32
-	MinModTime time.Time
-	MaxModTime time.Time
-	Display    string
+	Group      string `json:"group,omitempty"`
+	Label      string `json:"label"`
+	TotalBytes uint64 `json:"total_bytes"`
+	// AllocatedBytes is the space the files take on disk, less than TotalBytes for sparse files.
+	AllocatedBytes uint64 `json:"allocated_bytes"`
+	LineCount      int    `json:"line_count"`
+	// CodeLines, CommentLines and BlankLines split LineCount with --sloc.
+	CodeLines    int       `json:"code_lines"`
+	CommentLines int       `json:"comment_lines"`
+	BlankLines   int       `json:"blank_lines"`
+	FileCount    int32     `json:"file_count"`
+	MinModTime   time.Time `json:"min_mtime"`
+	MaxModTime   time.Time `json:"max_mtime"`
+	Display      string    `json:"-"`
 }
 
 type SummaryEntryMap map[string]SummaryEntry
 
 // FileSummary type represents the summary information for all files scanned.
 type FileSummary struct {
-	Root           string
-	RootDisplay    string
-	Total          uint64
-	MaxModTime     time.Time
-	MinModTime     time.Time
-	Entries        SummaryEntryMap
-	Groups         GroupMap
-	ExceptionCount int
+	Root        string `json:"root"`
+	RootDisplay string `json:"-"`
+	// Roots holds the totals of each root when the scan has several, it is empty for a single root.
+	Roots          []RootSummary   `json:"roots,omitempty"`
+	Total          uint64          `json:"total_bytes"`
+	Allocated      uint64          `json:"allocated_bytes"`
+	Files          int64           `json:"file_count"`
+	Lines          int64           `json:"line_count"`
+	Code           int64           `json:"code_lines"`
+	Comments       int64           `json:"comment_lines"`
+	Blanks         int64           `json:"blank_lines"`
+	MaxModTime     time.Time       `json:"max_mtime"`
+	MinModTime     time.Time       `json:"min_mtime"`
+	Entries        SummaryEntryMap `json:"entries"`
+	Groups         GroupMap        `json:"groups"`
+	ExceptionCount int             `json:"exception_count"`
+	// HardLinks counts the further links to files already counted, whose bytes weren't counted again.
+	HardLinks   int            `json:"hard_links"`
+	ErrorCounts map[string]int `json:"error_counts,omitempty"`
+	// SkipCounts counts the mount points, symlinks and symlink loops passed over, by kind.
+	SkipCounts map[string]int `json:"skip_counts,omitempty"`
+	Errors     []ScanError    `json:"errors,omitempty"`
+	// Details holds the files behind each entry, keyed by DetailKey. Top holds the top files of the whole scan. Both
+	// are only kept with --top or when browsing.
+	Details map[string]*EntryDetail `json:"-"`
+	Top     *TopFiles               `json:"-"`
 }
 
 // NewFileSummary construct a FileSummary instance.
 func NewFileSummary(root string) FileSummary {
 	summ := FileSummary{}
+	summ.Root = root
 	//m := make(map[string]int64)
 	summ.Entries = NewSummaryEntryMap()
 	summ.Groups = NewGroupMap()
@@ -63,8 +200,8 @@ func NewFileSummary(root string) FileSummary {
 
 // SummaryGroup type is a collection of entries that should be grouped together for display / sorting purposes.
 type SummaryGroup struct {
-	Name    string
-	Entries SummaryEntryMap
+	Name    string          `json:"name"`
+	Entries SummaryEntryMap `json:"entries"`
 }
 
 type EntryList []SummaryEntry
@@ -104,40 +241,39 @@ func NewGroupMap() GroupMap {
 }
 
 // AddEntry adds or updates a file entry into a SummaryEntryMap. Returns the entry created or updated.
-func (semap SummaryEntryMap) AddEntry(popts *ProgramOpts, fs *FileSummary, label string, path string, finfo os.FileInfo) SummaryEntry {
+func (semap SummaryEntryMap) AddEntry(popts *ProgramOpts, fs *FileSummary, label string, rec *FileRecord) SummaryEntry {
 	// Given a Map of SummaryEntries add or update one
-	fsize := finfo.Size()
+	finfo := rec.Info
+	fsize, allocated := rec.Sizes()
 	se, ok := semap[label]
 	if !ok {
 		se = NewSummaryEntry()
 		se.TotalBytes = 0
 		se.Label = label
 		se.FileCount = 0
-		se.MaxModTime = finfo.ModTime()
-		se.MinModTime = finfo.ModTime()
+		se.MaxModTime = rec.When()
+		se.MinModTime = rec.When()
 	}
 	se.Label = label
-	se.TotalBytes += uint64(fsize)
+	se.TotalBytes += fsize
+	se.AllocatedBytes += allocated
 	se.FileCount++
-	if finfo.ModTime().After(se.MaxModTime) {
-		se.MaxModTime = finfo.ModTime()
+	if rec.When().After(se.MaxModTime) {
+		se.MaxModTime = rec.When()
 	}
-	if finfo.ModTime().Before(se.MinModTime) {
-		se.MinModTime = finfo.ModTime()
+	if rec.When().Before(se.MinModTime) {
+		se.MinModTime = rec.When()
 	}
-	semap[label] = se
 	if popts.Lines {
-		lc, err := CountLines(fs, path)
-		if err != nil {
-			fs.ExceptionCount += 1
-			panic(err)
-		} else {
-			se.LineCount += lc
-		}
+		se.LineCount += rec.Lines
+		se.CodeLines += rec.Sloc.Code
+		se.CommentLines += rec.Sloc.Comment
+		se.BlankLines += rec.Sloc.Blank
 		if popts.Debug {
 			fmt.Printf("%s: lines = %d\n", finfo.Name(), se.LineCount)
 		}
 	}
+	semap[label] = se
 
 	return se
 }
@@ -163,95 +299,136 @@ func NewSummaryGroup(name string) SummaryGroup {
 }
 
 // AddEntryByExt add or update a file entry. Summarize by file extension.
-func (fs *FileSummary) AddEntryByExt(popts *ProgramOpts, fext string, path string, finfo os.FileInfo) SummaryEntry {
-	fsize := finfo.Size()
-	se := fs.Entries.AddEntry(popts, fs, fext, path, finfo)
+func (fs *FileSummary) AddEntryByExt(popts *ProgramOpts, fext string, rec *FileRecord) SummaryEntry {
+	se := fs.Entries.AddEntry(popts, fs, fext, rec)
+	fs.trackDetail(popts, "", fext, rec)
+	fs.addTotals(popts, rec)
 
-	if fs.MaxModTime.IsZero() || finfo.ModTime().After(fs.MaxModTime) {
-		fs.MaxModTime = finfo.ModTime()
-	}
-	if fs.MinModTime.IsZero() || finfo.ModTime().Before(fs.MinModTime) {
-		fs.MinModTime = finfo.ModTime()
+	return se
+}
+
+// AddEntryByTime add or update a file entry. Summarize by time period file was modified. Return the entry.
+func (fs *FileSummary) AddEntryByTime(popts *ProgramOpts, rec *FileRecord) SummaryEntry {
+	group, label := GetTimeGroup(popts, rec)
+	//fmt.Printf("%v: %v, %v\n", rec.Info.Name(), group, label)
+	se := fs.Groups.AddEntry(popts, fs, group, label, rec)
+	fs.trackDetail(popts, group, label, rec)
+	fs.addTotals(popts, rec)
+
+	//fmt.Printf("%+v\n", se)
+	//fmt.Printf("%+v\n", fs.Groups)
+	//fmt.Printf("fs.Total=%+v\n", fs.Total)
+
+	return se
+
+}
+
+// AddEntryBySize add or update a file entry. Summarize by size class, broken down by extension when fext is set.
+// Return the entry.
+func (fs *FileSummary) AddEntryBySize(popts *ProgramOpts, fext string, rec *FileRecord) SummaryEntry {
+	group, label := SizeClass(uint64(rec.Info.Size()))
+	if fext != "" {
+		label += " " + fext
 	}
-	fs.Entries[fext] = se
-	fs.Total += uint64(fsize)
-	if popts.Lines {
-		lc, err := CountLines(fs, path)
-		if err != nil {
-			fs.ExceptionCount += 1
-			panic(err)
+	se := fs.Groups.AddEntry(popts, fs, group, label, rec)
+	fs.trackDetail(popts, group, label, rec)
+	fs.addTotals(popts, rec)
+
+	return se
+}
+
+// AddEntryByKeys add or update a file entry of a cross tab: the row label is the group, the column label the entry.
+// Return the entry.
+func (fs *FileSummary) AddEntryByKeys(popts *ProgramOpts, row string, col string, rec *FileRecord) SummaryEntry {
+	se := fs.Groups.AddEntry(popts, fs, row, col, rec)
+	fs.trackDetail(popts, row, col, rec)
+	fs.addTotals(popts, rec)
+
+	return se
+}
+
+// AddEntryByDir add or update a file entry under each of the directories it rolls up into. When label is set the
+// directories are groups broken down by label, otherwise each directory is an entry. Return the innermost entry.
+func (fs *FileSummary) AddEntryByDir(popts *ProgramOpts, dirs []string, label string, rec *FileRecord) SummaryEntry {
+	var se SummaryEntry
+	for _, dir := range dirs {
+		if label == "" {
+			se = fs.Entries.AddEntry(popts, fs, dir, rec)
+			fs.trackDetail(popts, "", dir, rec)
 		} else {
-			se.LineCount += lc
-		}
-		if popts.Debug {
-			fmt.Printf("%s: lines = %d\n", finfo.Name(), se.LineCount)
+			se = fs.Groups.AddEntry(popts, fs, dir, label, rec)
+			fs.trackDetail(popts, dir, label, rec)
 		}
 	}
+	fs.addTotals(popts, rec)
 
 	return se
 }
 
-// AddEntryByTime add or update a file entry. Summarize by time period file was modified. Return the entry.
-func (fs *FileSummary) AddEntryByTime(popts *ProgramOpts, path string, finfo os.FileInfo) SummaryEntry {
-	// Pass
-	group, label := GetTimeGroup(finfo)
-	//fmt.Printf("%v: %v, %v\n", finfo.Name(), group, label)
-	fsize := finfo.Size()
-	se := fs.Groups.AddEntry(popts, fs, group, label, path, finfo)
-	se.Label = label
-	if finfo.ModTime().After(se.MaxModTime) {
-		se.MaxModTime = finfo.ModTime()
+// addTotals counts a file in the scan totals.
+func (fs *FileSummary) addTotals(popts *ProgramOpts, rec *FileRecord) {
+	when := rec.When()
+	if fs.MaxModTime.IsZero() || when.After(fs.MaxModTime) {
+		fs.MaxModTime = when
 	}
-	if finfo.ModTime().Before(se.MinModTime) {
-		se.MinModTime = finfo.ModTime()
+	if fs.MinModTime.IsZero() || when.Before(fs.MinModTime) {
+		fs.MinModTime = when
 	}
-	if fs.MaxModTime.IsZero() || finfo.ModTime().After(fs.MaxModTime) {
-		fs.MaxModTime = finfo.ModTime()
+	fsize, allocated := rec.Sizes()
+	fs.Total += fsize
+	fs.Allocated += allocated
+	fs.Files++
+	fs.Lines += int64(rec.Lines)
+	fs.Code += int64(rec.Sloc.Code)
+	fs.Comments += int64(rec.Sloc.Comment)
+	fs.Blanks += int64(rec.Sloc.Blank)
+	if rec.Linked {
+		fs.HardLinks++
 	}
-	if fs.MinModTime.IsZero() || finfo.ModTime().Before(fs.MinModTime) {
-		fs.MinModTime = finfo.ModTime()
+	if rs := fs.rootNamed(rec.Root); rs != nil {
+		rs.Total += fsize
+		rs.Allocated += allocated
+		rs.Files++
+		rs.Lines += int64(rec.Lines)
 	}
-	fs.Total += uint64(fsize)
-	if popts.Lines {
-		lc, err := CountLines(fs, path)
-		if err != nil {
-			fs.ExceptionCount += 1
-			panic(err)
-		} else {
-			se.LineCount += lc
-		}
-	}
-
-	//fmt.Printf("%+v\n", se)
-	//fmt.Printf("%+v\n", fs.Groups)
-	//fmt.Printf("fs.Total=%+v\n", fs.Total)
+	fs.trackTop(popts, rec)
+}
 
-	return se
+// DirLabels lists the directories a file in dir rolls up into, from the root "." down. dir is relative to the scan
+// root. With a depth above zero, directories deeper than depth are rolled into their ancestor at that depth.
+func DirLabels(dir string, depth int) []string {
+	labels := []string{"."}
+	dir = filepath.ToSlash(dir)
+	if dir == "." || dir == "" {
+		return labels
+	}
 
+	comps := strings.Split(dir, "/")
+	if depth > 0 && len(comps) > depth {
+		comps = comps[:depth]
+	}
+	for idx := range comps {
+		labels = append(labels, strings.Join(comps[:idx+1], "/"))
+	}
+	return labels
 }
 
-// GetTimeGroup determines time group and label for a file. Group is a broad grouping of the files 'less than a month', 'less than a year', 'older'.
-// Label is something like YYYYMMDD
-func GetTimeGroup(finfo os.FileInfo) (string, string) {
-	modtime := finfo.ModTime()
-	now := time.Now()
-	group := ""
-	label := modtime.Format(DateOnly)
+// defaultTimeBuckets : the buckets of a --time summary when none were loaded.
+var defaultTimeBuckets, _ = ParseTimeBuckets(DefaultTimeBuckets, time.Local)
 
-	age := int(now.Sub(modtime).Hours() / 24.0)
-	//fmt.Printf("modtime=%v ageindays=%d\n", modtime, age)
+// GetTimeGroup determines time group and label for a file from the --time buckets. By default the group is a broad
+// grouping of the files 'less than a month', 'less than a year', 'older', and the label is something like YYYY-MM-DD,
+// see TimeBuckets.Group. Files are dated by the --time-field timestamp.
+func GetTimeGroup(opts *ProgramOpts, rec *FileRecord) (string, string) {
+	return opts.GetTimeBuckets().Group(rec.When(), opts.Now())
+}
 
-	if age < 30 {
-		group = "01month"
-	} else if age < 365 {
-		group = "02year"
-		label = label[0:7]
-	} else {
-		group = "03older"
-		label = label[0:4]
+// GetTimeBuckets returns the --time buckets loaded, the default buckets when none were.
+func (opts *ProgramOpts) GetTimeBuckets() *TimeBuckets {
+	if opts.TimeBuckets == nil {
+		return defaultTimeBuckets
 	}
-
-	return group, label
+	return opts.TimeBuckets
 }
 
 // TODO: Should I stay or should I go?
@@ -263,12 +440,12 @@ func (sg *SummaryGroup) AddEntry(popts *ProgramOpts, fs *FileSummary, label stri
 */
 
 // AddEntry method for GroupMap objects. Add or update a file entry to the specified group and label. Return the entry.
-func (gm GroupMap) AddEntry(popts *ProgramOpts, fs *FileSummary, group string, label string, path string, finfo os.FileInfo) SummaryEntry {
+func (gm GroupMap) AddEntry(popts *ProgramOpts, fs *FileSummary, group string, label string, rec *FileRecord) SummaryEntry {
 	sg, ok := gm[group]
 	if !ok {
 		sg = NewSummaryGroup(group)
 	}
-	se := sg.Entries.AddEntry(popts, fs, label, path, finfo)
+	se := sg.Entries.AddEntry(popts, fs, label, rec)
 	//sg.Entry.TotalBytes += uint64(finfo.Size())
 	//sg.Entry.FileCount++
 	gm[group] = sg
@@ -277,8 +454,73 @@ func (gm GroupMap) AddEntry(popts *ProgramOpts, fs *FileSummary, group string, l
 }
 
 // AddEntryToGroup method for FileSummary objects. Add or update a file entry based on group and label membership. Return the entry.
-func (fs *FileSummary) AddEntryToGroup(popts *ProgramOpts, group string, label string, path string, finfo os.FileInfo) SummaryEntry {
-	return fs.Groups.AddEntry(popts, fs, group, label, path, finfo)
+func (fs *FileSummary) AddEntryToGroup(popts *ProgramOpts, group string, label string, rec *FileRecord) SummaryEntry {
+	fs.trackDetail(popts, group, label, rec)
+	return fs.Groups.AddEntry(popts, fs, group, label, rec)
+}
+
+// AddError records a scan error, counting it in ExceptionCount and by kind.
+func (fs *FileSummary) AddError(serr ScanError) {
+	fs.ExceptionCount++
+	if rs := fs.rootOf(serr.Path); rs != nil {
+		rs.ExceptionCount++
+	}
+	if fs.ErrorCounts == nil {
+		fs.ErrorCounts = make(map[string]int)
+	}
+	fs.ErrorCounts[serr.Kind]++
+	if len(fs.Errors) < MaxStoredErrors {
+		fs.Errors = append(fs.Errors, serr)
+	}
+}
+
+// ErrorBreakdown formats the error counts by kind for the status line, e.g. "perm:3 io:1".
+func (fs *FileSummary) ErrorBreakdown() string {
+	return formatCounts(fs.ErrorCounts)
+}
+
+// AddSkipped counts a path the scan passed over on purpose, by kind.
+func (fs *FileSummary) AddSkipped(path string, kind string) {
+	if fs.SkipCounts == nil {
+		fs.SkipCounts = make(map[string]int)
+	}
+	fs.SkipCounts[kind]++
+}
+
+// SkipBreakdown formats the skipped path counts by kind for the status line, e.g. "mount:2 symlink:14".
+func (fs *FileSummary) SkipBreakdown() string {
+	return formatCounts(fs.SkipCounts)
+}
+
+// formatCounts formats counts by kind in kind order.
+func formatCounts(counts map[string]int) string {
+	kinds := make([]string, 0, len(counts))
+	for kind := range counts {
+		kinds = append(kinds, kind)
+	}
+	sort.Strings(kinds)
+
+	parts := make([]string, 0, len(kinds))
+	for _, kind := range kinds {
+		parts = append(parts, fmt.Sprintf("%s:%d", kind, counts[kind]))
+	}
+	return strings.Join(parts, " ")
+}
+
+// AllEntries flattens the extension entries and the entries of every group into one list. Entries taken from a
+// group carry the group name.
+func (fs *FileSummary) AllEntries() EntryList {
+	el := make(EntryList, 0, len(fs.Entries))
+	for _, entry := range fs.Entries {
+		el = append(el, entry)
+	}
+	for name, group := range fs.Groups {
+		for _, entry := range group.Entries {
+			entry.Group = name
+			el = append(el, entry)
+		}
+	}
+	return el
 }
 
 // SortEntriesByBytes given a map of entries sort them by bytes.
@@ -294,6 +536,20 @@ func SortEntriesByBytes(summ map[string]SummaryEntry) EntryList {
 	return el
 }
 
+// SortEntriesBySize given a map of entries sort them by the size --size picks.
+func SortEntriesBySize(opts *ProgramOpts, summ map[string]SummaryEntry) EntryList {
+	el := make(EntryList, 0, len(summ))
+	for _, entry := range summ {
+		el = append(el, entry)
+	}
+
+	sort.Slice(el, func(i, j int) bool {
+		return opts.EntrySize(el[i]) > opts.EntrySize(el[j])
+	})
+
+	return el
+}
+
 // SortEntriesByLines given a map of entries sort them by line count.
 func SortEntriesByLines(summ map[string]SummaryEntry) EntryList {
 	el := make(EntryList, 0, len(summ))
@@ -308,9 +564,52 @@ func SortEntriesByLines(summ map[string]SummaryEntry) EntryList {
 	return el
 }
 
+// SortEntriesByCode given a map of entries sort them by lines of code.
+func SortEntriesByCode(summ map[string]SummaryEntry) EntryList {
+	el := make(EntryList, 0, len(summ))
+	for _, entry := range summ {
+		el = append(el, entry)
+	}
+
+	sort.Slice(el, func(i, j int) bool {
+		return el[i].CodeLines > el[j].CodeLines
+	})
+
+	return el
+}
+
+// SortKeys : the orders an entry list can be sorted in, cycled through by the interactive browser.
+var SortKeys = []string{"bytes", "files", "lines", "code", "label", "newest"}
+
+// SortEntryList sorts entries by one of the SortKeys, bytes being the size --size picks. Largest, most recent or
+// alphabetically first. Ties keep the order they came in.
+func SortEntryList(opts *ProgramOpts, el EntryList, key string) {
+	sort.SliceStable(el, func(i, j int) bool {
+		a, b := el[i], el[j]
+		switch key {
+		case "files":
+			return a.FileCount > b.FileCount
+		case "lines":
+			return a.LineCount > b.LineCount
+		case "code":
+			return a.CodeLines > b.CodeLines
+		case "label":
+			if a.Group != b.Group {
+				return a.Group < b.Group
+			}
+			return a.Label < b.Label
+		case "newest":
+			return a.MaxModTime.After(b.MaxModTime)
+		}
+		return opts.EntrySize(a) > opts.EntrySize(b)
+	})
+}
+
 // Calculate an appropriate RootPath for display taking into consideration the terminal size
 func (self *FileSummary) SetDisplayRootPath(opts *ProgramOpts) {
-	fmt.Printf("SetDisplayRootPath ConCols=%d", opts.ConCols)
+	if opts.Debug {
+		fmt.Printf("SetDisplayRootPath ConCols=%d\n", opts.ConCols)
+	}
 	rootpathlen := len(self.Root)
 	if opts.ConCols <= 97 || rootpathlen+95 < opts.ConCols {
 		// Plenty of room to display the root in it's entirety
@@ -0,0 +1,66 @@
+// core package contains all the components needed by the summarizefiles utility.
+package core
+
+import (
+	"os"
+	"os/user"
+	"strconv"
+	"sync"
+)
+
+// OwnerUnknown : the owner and group of files on platforms whose stat doesn't say.
+const OwnerUnknown = "(unknown)"
+
+// OwnerNames type resolves the user and group ids owning files to names. Every id is looked up once, ids without a
+// name, such as those of a user since deleted or from another machine's NFS export, are shown as the number.
+type OwnerNames struct {
+	mu     sync.Mutex
+	users  map[uint32]string
+	groups map[uint32]string
+}
+
+// NewOwnerNames construct an OwnerNames instance.
+func NewOwnerNames() *OwnerNames {
+	return &OwnerNames{users: make(map[uint32]string), groups: make(map[uint32]string)}
+}
+
+// Lookup returns the names of the user and group owning a file.
+func (on *OwnerNames) Lookup(info os.FileInfo) (string, string) {
+	uid, gid, ok := ownerOf(info)
+	if !ok {
+		return OwnerUnknown, OwnerUnknown
+	}
+	return on.User(uid), on.Group(gid)
+}
+
+// User returns the name of a user id, the id itself when it has none.
+func (on *OwnerNames) User(uid uint32) string {
+	on.mu.Lock()
+	defer on.mu.Unlock()
+	if name, ok := on.users[uid]; ok {
+		return name
+	}
+	id := strconv.FormatUint(uint64(uid), 10)
+	name := id
+	if u, err := user.LookupId(id); err == nil {
+		name = u.Username
+	}
+	on.users[uid] = name
+	return name
+}
+
+// Group returns the name of a group id, the id itself when it has none.
+func (on *OwnerNames) Group(gid uint32) string {
+	on.mu.Lock()
+	defer on.mu.Unlock()
+	if name, ok := on.groups[gid]; ok {
+		return name
+	}
+	id := strconv.FormatUint(uint64(gid), 10)
+	name := id
+	if g, err := user.LookupGroupId(id); err == nil {
+		name = g.Name
+	}
+	on.groups[gid] = name
+	return name
+}
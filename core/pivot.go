@@ -0,0 +1,261 @@
+// core package contains all the components needed by the summarizefiles utility.
+package core
+
+import (
+	"fmt"
+	"sort"
+	"strconv"
+)
+
+const (
+	CellBytes = "bytes"
+	CellFiles = "files"
+	CellLines = "lines"
+)
+
+// CellKinds : what the cells of a --by cross tab can count.
+var CellKinds = []string{CellBytes, CellFiles, CellLines}
+
+// ValidCellKind reports whether kind is one of the CellKinds.
+func ValidCellKind(kind string) bool {
+	for _, known := range CellKinds {
+		if kind == known {
+			return true
+		}
+	}
+	return false
+}
+
+// CellKind returns what cross tab cells count: --cell when given, lines with --lines, bytes otherwise.
+func (opts *ProgramOpts) CellKind() string {
+	if opts.Cell != "" {
+		return opts.Cell
+	}
+	if opts.Lines {
+		return CellLines
+	}
+	return CellBytes
+}
+
+// CellValue is what an entry adds to its cross tab cell, the size --size picks when counting bytes.
+func (opts *ProgramOpts) CellValue(entry SummaryEntry) uint64 {
+	switch opts.CellKind() {
+	case CellFiles:
+		return uint64(entry.FileCount)
+	case CellLines:
+		return uint64(entry.LineCount)
+	}
+	return opts.EntrySize(entry)
+}
+
+// PivotTable type is a --by cross tab: a value for every pair of row and column labels, with the totals of each row
+// and column. Rows and Cols are in display order once sorted.
+type PivotTable struct {
+	RowKey    string
+	ColKey    string
+	Rows      []string
+	Cols      []string
+	RowTotals map[string]uint64
+	ColTotals map[string]uint64
+	Total     uint64
+	cells     map[string]map[string]uint64
+	// buckets holds the --time bucket group of every label of a time key, labels sort by it.
+	buckets map[string]string
+}
+
+// NewPivotTable construct an empty PivotTable instance for a row and a column key.
+func NewPivotTable(rowKey string, colKey string) *PivotTable {
+	return &PivotTable{
+		RowKey:    rowKey,
+		ColKey:    colKey,
+		RowTotals: make(map[string]uint64),
+		ColTotals: make(map[string]uint64),
+		cells:     make(map[string]map[string]uint64),
+		buckets:   make(map[string]string),
+	}
+}
+
+// NewSummaryPivot construct the PivotTable of a summary made with two --by keys, its groups being the rows and
+// their entries the columns.
+func NewSummaryPivot(opts *ProgramOpts, summ *FileSummary) *PivotTable {
+	keys := opts.ByKeys()
+	pt := NewPivotTable(keys[0], keys[1])
+	tb := opts.GetTimeBuckets()
+	now := opts.Now()
+	for name, group := range summ.Groups {
+		for _, entry := range group.Entries {
+			pt.Add(name, entry.Label, opts.CellValue(entry))
+			// every file of a time label falls in the same bucket, any of them tells which
+			if pt.RowKey == KeyTime {
+				pt.buckets[name], _ = tb.Group(entry.MaxModTime, now)
+			}
+			if pt.ColKey == KeyTime {
+				pt.buckets[entry.Label], _ = tb.Group(entry.MaxModTime, now)
+			}
+		}
+	}
+	pt.Sort()
+	return pt
+}
+
+// Add adds a value to the cell of a row and column.
+func (pt *PivotTable) Add(row string, col string, value uint64) {
+	cols, ok := pt.cells[row]
+	if !ok {
+		cols = make(map[string]uint64)
+		pt.cells[row] = cols
+		pt.Rows = append(pt.Rows, row)
+	}
+	if _, ok := pt.ColTotals[col]; !ok {
+		pt.Cols = append(pt.Cols, col)
+	}
+	cols[col] += value
+	pt.RowTotals[row] += value
+	pt.ColTotals[col] += value
+	pt.Total += value
+}
+
+// Cell returns the value of a row and column, zero when no file had both labels.
+func (pt *PivotTable) Cell(row string, col string) uint64 {
+	return pt.cells[row][col]
+}
+
+// Sort puts the rows and columns in display order: in their own order for sizes and times, otherwise largest first.
+func (pt *PivotTable) Sort() {
+	pt.sortLabels(pt.Rows, pt.RowKey, pt.RowTotals)
+	pt.sortLabels(pt.Cols, pt.ColKey, pt.ColTotals)
+}
+
+// sortLabels sorts the labels of a key, by the key's own order or by their totals, largest first. --time labels go
+// in bucket order and newest first within a bucket, as --time lists them.
+func (pt *PivotTable) sortLabels(labels []string, key string, totals map[string]uint64) {
+	sort.Slice(labels, func(i, j int) bool {
+		a, b := labels[i], labels[j]
+		switch {
+		case key == KeyTime && pt.buckets[a] != pt.buckets[b]:
+			return pt.buckets[a] < pt.buckets[b]
+		case key == KeyTime:
+			return a > b
+		case keyOrdered(key):
+			return keyLess(key, a, b)
+		case totals[a] != totals[b]:
+			return totals[a] > totals[b]
+		}
+		return a < b
+	})
+}
+
+// PivotEntries lists the entries of a cross tab summary in display order, row by row, each entry carrying its row
+// as its group.
+func PivotEntries(opts *ProgramOpts, summ *FileSummary) EntryList {
+	pt := NewSummaryPivot(opts, summ)
+	el := make(EntryList, 0, len(pt.Rows)*len(pt.Cols))
+	for _, row := range pt.Rows {
+		group := summ.Groups[row]
+		for _, col := range pt.Cols {
+			entry, ok := group.Entries[col]
+			if !ok {
+				continue
+			}
+			entry.Group = row
+			el = append(el, entry)
+		}
+	}
+	return el
+}
+
+// formatCell formats a cross tab value for the console: a human size for bytes, a count otherwise.
+func formatCell(kind string, value uint64) string {
+	if kind == CellBytes {
+		return humansize(value)
+	}
+	return strconv.FormatUint(value, 10)
+}
+
+// RenderPivot formats a cross tab summary as a table for the console, with a total column and a total row. Every
+// column is as wide as its longest label or value. When the columns are wider than the terminal they are wrapped
+// into blocks one under the other, each repeating the row labels, and the last ending with the totals. Rows that
+// don't fit the screen are left out, except in batch mode.
+func RenderPivot(opts *ProgramOpts, summ *FileSummary) []string {
+	pt := NewSummaryPivot(opts, summ)
+	kind := opts.CellKind()
+	const total = "(total)"
+
+	corner := pt.RowKey + `\` + pt.ColKey
+	labelwidth := len(corner)
+	for _, row := range append([]string{total}, pt.Rows...) {
+		if len(row) > labelwidth {
+			labelwidth = len(row)
+		}
+	}
+
+	// the total column is the last one, its values are the row totals
+	cols := append(append([]string{}, pt.Cols...), total)
+	value := func(row string, col string) uint64 {
+		switch {
+		case row == total && col == total:
+			return pt.Total
+		case row == total:
+			return pt.ColTotals[col]
+		case col == total:
+			return pt.RowTotals[row]
+		}
+		return pt.Cell(row, col)
+	}
+	rows := append(append([]string{}, pt.Rows...), total)
+	widths := make(map[string]int, len(cols))
+	for _, col := range cols {
+		widths[col] = len(col)
+		for _, row := range rows {
+			if width := len(formatCell(kind, value(row, col))); width > widths[col] {
+				widths[col] = width
+			}
+		}
+	}
+
+	// wrap the columns into blocks that fit the terminal, at least one column a block
+	var blocks [][]string
+	var block []string
+	used := labelwidth
+	for _, col := range cols {
+		if len(block) > 0 && opts.ConCols > 0 && used+1+widths[col] > opts.ConCols {
+			blocks = append(blocks, block)
+			block, used = nil, labelwidth
+		}
+		block = append(block, col)
+		used += 1 + widths[col]
+	}
+	blocks = append(blocks, block)
+
+	shown := pt.Rows
+	if !opts.Batch && opts.ConRows > 0 {
+		// every block takes a header, a total and, but for the first, a blank line
+		room := (opts.ConRows+1)/len(blocks) - 3
+		if room < 1 {
+			room = 1
+		}
+		if len(shown) > room {
+			shown = shown[:room]
+		}
+	}
+
+	var lines []string
+	for idx, block := range blocks {
+		if idx > 0 {
+			lines = append(lines, "")
+		}
+		header := fmt.Sprintf("%-*s", labelwidth, corner)
+		for _, col := range block {
+			header += fmt.Sprintf(" %*s", widths[col], col)
+		}
+		lines = append(lines, header)
+		for _, row := range append(append([]string{}, shown...), total) {
+			line := fmt.Sprintf("%-*s", labelwidth, row)
+			for _, col := range block {
+				line += fmt.Sprintf(" %*s", widths[col], formatCell(kind, value(row, col)))
+			}
+			lines = append(lines, line)
+		}
+	}
+	return lines
+}
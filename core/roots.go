@@ -0,0 +1,131 @@
+// core package contains all the components needed by the summarizefiles utility.
+package core
+
+import (
+	"fmt"
+	"path/filepath"
+	"strings"
+)
+
+// RootSummary type is the totals of one of the roots of a scan over several.
+type RootSummary struct {
+	Root           string `json:"root"`
+	Total          uint64 `json:"total_bytes"`
+	Allocated      uint64 `json:"allocated_bytes"`
+	Files          int64  `json:"file_count"`
+	Lines          int64  `json:"line_count"`
+	ExceptionCount int    `json:"exception_count"`
+}
+
+// NewRootsSummary construct a FileSummary instance for a scan over several roots. Root lists them all, and Roots
+// keeps the totals of each. A single root makes a plain FileSummary.
+func NewRootsSummary(roots []string) FileSummary {
+	if len(roots) == 1 {
+		return NewFileSummary(roots[0])
+	}
+	summ := NewFileSummary(strings.Join(roots, " "))
+	summ.Roots = make([]RootSummary, 0, len(roots))
+	for _, root := range roots {
+		summ.Roots = append(summ.Roots, RootSummary{Root: root})
+	}
+	return summ
+}
+
+// rootNamed returns the totals of a root by the name the scanner was given it under, nil for a single root scan.
+func (fs *FileSummary) rootNamed(root string) *RootSummary {
+	for idx := range fs.Roots {
+		if fs.Roots[idx].Root == root {
+			return &fs.Roots[idx]
+		}
+	}
+	return nil
+}
+
+// rootOf returns the totals of the root path is under, nil for a single root scan. It is for paths that don't carry
+// their root, such as those of scan errors.
+func (fs *FileSummary) rootOf(path string) *RootSummary {
+	for idx := range fs.Roots {
+		if isUnderRoot(path, fs.Roots[idx].Root) {
+			return &fs.Roots[idx]
+		}
+	}
+	return nil
+}
+
+// isUnderRoot reports whether path was found under root. The scanner joins paths, so those found under "." carry no
+// "./" prefix, any relative path not leading out of the working directory is under it.
+func isUnderRoot(path string, root string) bool {
+	path, root = filepath.Clean(path), filepath.Clean(root)
+	if root == "." {
+		return !filepath.IsAbs(path) && path != ".." && !strings.HasPrefix(path, ".."+string(filepath.Separator))
+	}
+	return path == root || strings.HasPrefix(path, strings.TrimSuffix(root, string(filepath.Separator))+string(filepath.Separator))
+}
+
+// PathRoot is the root paths are shown relative to, empty when the scan has several.
+func (fs *FileSummary) PathRoot() string {
+	if len(fs.Roots) > 0 {
+		return ""
+	}
+	return fs.Root
+}
+
+// NormalizeRoots cleans up the roots given on the command line. A root given twice, or inside another root, would be
+// counted twice, so it is dropped with a warning. Roots are compared once symlinks are resolved, but kept as given,
+// only cleaned so they prefix the paths found under them.
+func NormalizeRoots(roots []string) ([]string, []string) {
+	type resolvedRoot struct {
+		given    string
+		resolved string
+	}
+
+	var warnings []string
+	resolved := make([]resolvedRoot, 0, len(roots))
+	for _, root := range roots {
+		abs, err := filepath.Abs(root)
+		if err != nil {
+			abs = root
+		}
+		if real, err := filepath.EvalSymlinks(abs); err == nil {
+			abs = real
+		}
+		resolved = append(resolved, resolvedRoot{given: root, resolved: abs})
+	}
+
+	kept := make([]string, 0, len(roots))
+	for idx, root := range resolved {
+		dropped := false
+		for other, outer := range resolved {
+			if other == idx {
+				continue
+			}
+			if root.resolved == outer.resolved {
+				// keep the first of duplicates
+				if other < idx {
+					warnings = append(warnings, fmt.Sprintf("%s is the same as %s, scanning it once", root.given, outer.given))
+					dropped = true
+					break
+				}
+				continue
+			}
+			if isInside(root.resolved, outer.resolved) {
+				warnings = append(warnings, fmt.Sprintf("%s is inside %s, scanning it as part of %s", root.given, outer.given, outer.given))
+				dropped = true
+				break
+			}
+		}
+		if !dropped {
+			kept = append(kept, filepath.Clean(root.given))
+		}
+	}
+	return kept, warnings
+}
+
+// isInside reports whether path is below dir.
+func isInside(path string, dir string) bool {
+	rel, err := filepath.Rel(dir, path)
+	if err != nil || rel == "." {
+		return false
+	}
+	return rel != ".." && !strings.HasPrefix(rel, ".."+string(filepath.Separator))
+}
@@ -0,0 +1,596 @@
+// core package contains all the components needed by the summarizefiles utility.
+package core
+
+import (
+	"io/fs"
+	"os"
+	"path/filepath"
+	"runtime"
+	"sort"
+	"sync"
+	"time"
+)
+
+// FileRecord type carries everything a scan worker learned about a single file to the summary.
+type FileRecord struct {
+	// Root is the root the file was found under
+	Root  string
+	Path  string
+	Info  os.FileInfo
+	Lines int
+	// Sloc splits Lines into code, comment and blank lines with --sloc.
+	Sloc LineStats
+	// Language is the language of the file with --lang.
+	Language string
+	// Owner and OwnerGroup name the user and group owning the file with --by owner or group.
+	Owner      string
+	OwnerGroup string
+	Hash       string
+	// Time is the timestamp --time-field picks, what the file is grouped and dated by.
+	Time time.Time
+	// Err is set when the file was listed and stat'd but its content couldn't be read, Lines and Hash are then unset.
+	Err error
+	// Allocated is the space the file takes on disk. Linked marks a further hard link to a file already counted, its
+	// bytes and lines only count toward the link with the lexically smallest path.
+	Allocated uint64
+	Linked    bool
+}
+
+// Sizes returns the apparent and allocated bytes the file adds to a summary, zero for a further hard link.
+func (rec *FileRecord) Sizes() (uint64, uint64) {
+	if rec.Linked {
+		return 0, 0
+	}
+	return uint64(rec.Info.Size()), rec.Allocated
+}
+
+// When returns the timestamp the file is grouped and dated by, its modification time unless the scan read another.
+func (rec *FileRecord) When() time.Time {
+	if rec.Time.IsZero() {
+		return rec.Info.ModTime()
+	}
+	return rec.Time
+}
+
+// VisitFunc is called once for every file scanned. It runs with the scanner lock held so it may update a summary.
+type VisitFunc func(rec *FileRecord)
+
+// ErrorFunc is called for every path the scan couldn't fully examine. It runs with the scanner lock held.
+type ErrorFunc func(serr ScanError)
+
+const (
+	SkipMount   = "mount"
+	SkipSymlink = "symlink"
+	SkipLoop    = "loop"
+)
+
+// SkipFunc is called for every path the scan deliberately passes over: a mount point with --one-file-system, a
+// symlink when not following them, or a directory already scanned reached again through a symlink. It runs with the
+// scanner lock held.
+type SkipFunc func(path string, kind string)
+
+// Scanner type walks directory trees with parallel directory readers and feeds the files found to a pool of
+// workers. Workers stat the file and count its lines, then hand the record to Visit one at a time. Every root is
+// read at the same time, sharing the readers and workers.
+type Scanner struct {
+	Roots    []string
+	Jobs     int
+	Opts     *ProgramOpts
+	Visit    VisitFunc
+	Error    ErrorFunc
+	Skip     SkipFunc
+	Refresh  func()
+	Interval time.Duration
+	Filter   *Filter
+	// Languages detects the language of every file with --lang, the built in table is used when left nil.
+	Languages *LanguageMap
+	// Owners resolves the user and group owning every file with --by owner or group, created when left nil.
+	Owners *OwnerNames
+
+	// mu serializes Visit and Refresh so the summary is never observed half updated.
+	mu sync.Mutex
+
+	// lmu guards the inodes of the hard linked files and, when following symlinks, the directories already seen.
+	lmu   sync.Mutex
+	links map[inodeKey]bool
+	// held keeps the records of files with several hard links until every link is found and the owner is known.
+	held     map[inodeKey][]*FileRecord
+	dirsSeen map[inodeKey]bool
+	rootDevs map[string]uint64
+
+	// qmu guards the directory queue and the scan error.
+	qmu     sync.Mutex
+	qcond   *sync.Cond
+	dirs    []scanDir
+	pending int
+	stopped bool
+	err     error
+}
+
+// scanDir type is a directory waiting to be read.
+type scanDir struct {
+	root string
+	path string
+	// rel is the slash separated path relative to the root, empty for the root itself
+	rel    string
+	ignore *IgnoreList
+}
+
+// inodeKey type identifies a file whatever path it was reached by.
+type inodeKey struct {
+	dev uint64
+	ino uint64
+}
+
+// scanItem type is a file found by a directory reader, waiting on a worker.
+type scanItem struct {
+	root  string
+	path  string
+	entry fs.DirEntry
+	info  os.FileInfo
+}
+
+// NewScanner construct a Scanner instance for the tree at root, more roots can be added to Roots before Run. Jobs
+// defaults to GOMAXPROCS when the options don't say.
+func NewScanner(root string, opts *ProgramOpts, visit VisitFunc) *Scanner {
+	s := &Scanner{}
+	s.Roots = []string{root}
+	s.Opts = opts
+	s.Visit = visit
+	s.Jobs = opts.Jobs
+	if s.Jobs < 1 {
+		s.Jobs = runtime.GOMAXPROCS(0)
+	}
+	s.Interval = 300 * time.Millisecond
+	s.qcond = sync.NewCond(&s.qmu)
+	s.links = make(map[inodeKey]bool)
+	s.held = make(map[inodeKey][]*FileRecord)
+	s.dirsSeen = make(map[inodeKey]bool)
+	s.rootDevs = make(map[string]uint64)
+	return s
+}
+
+// Locked runs fn while holding the lock workers take to update the summary.
+func (s *Scanner) Locked(fn func()) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	fn()
+}
+
+// Run scans the trees. It returns after every file has been visited. Errors are handed to Error and the scan carries
+// on, unless Error is nil or the options ask to fail on error, then the scan stops at the first error and returns it.
+func (s *Scanner) Run() error {
+	// roots are followed when they are symlinks, it's what was asked for
+	rootinfos := make([]os.FileInfo, len(s.Roots))
+	for idx, root := range s.Roots {
+		rootinfo, err := os.Stat(root)
+		if err != nil {
+			// a root that can't be read is reported like any other path, the others are still scanned
+			s.report(root, "stat", err)
+			if s.isStopped() {
+				return s.err
+			}
+			continue
+		}
+		rootinfos[idx] = rootinfo
+		if id, _, _, ok := inodeOf(rootinfo); ok {
+			s.rootDevs[root] = id.dev
+			s.dirsSeen[id] = true
+		}
+	}
+	var err error
+	if s.Filter == nil {
+		s.Filter, err = NewFilter(s.Opts)
+		if err != nil {
+			return err
+		}
+	}
+	if s.Owners == nil && (s.Opts.UsesKey(KeyOwner) || s.Opts.UsesKey(KeyGroup)) {
+		s.Owners = NewOwnerNames()
+	}
+	if s.Languages == nil && (s.Opts.Lang || s.Opts.UsesKey(KeyLang)) {
+		s.Languages, err = NewLanguageMap(s.Opts)
+		if err != nil {
+			return err
+		}
+	}
+
+	items := make(chan scanItem, s.Jobs*64)
+
+	var workers sync.WaitGroup
+	for idx := 0; idx < s.Jobs; idx++ {
+		workers.Add(1)
+		go func() {
+			defer workers.Done()
+			for item := range items {
+				s.work(item)
+			}
+		}()
+	}
+
+	done := make(chan struct{})
+	refreshed := make(chan struct{})
+	go s.refreshLoop(done, refreshed)
+
+	for idx, root := range s.Roots {
+		if rootinfos[idx] == nil {
+			continue
+		}
+		if rootinfos[idx].IsDir() {
+			s.dirs = append(s.dirs, scanDir{root: root, path: root})
+			s.pending++
+		} else {
+			items <- scanItem{root: root, path: root, info: rootinfos[idx]}
+		}
+	}
+	if s.pending > 0 {
+		var readers sync.WaitGroup
+		for idx := 0; idx < s.Jobs; idx++ {
+			readers.Add(1)
+			go func() {
+				defer readers.Done()
+				s.readDirs(items)
+			}()
+		}
+		readers.Wait()
+	}
+
+	close(items)
+	workers.Wait()
+	if !s.isStopped() {
+		s.visitLinks()
+	}
+	close(done)
+	<-refreshed
+
+	return s.err
+}
+
+// readDirs pops directories off the queue until the tree is exhausted, queueing subdirectories and sending files
+// to the workers.
+func (s *Scanner) readDirs(items chan<- scanItem) {
+	for {
+		dir, ok := s.nextDir()
+		if !ok {
+			return
+		}
+
+		entries, err := os.ReadDir(dir.path)
+		if err != nil {
+			// ReadDir returns whatever it read before the error, summarize those
+			s.report(dir.path, "readdir", err)
+		}
+		if s.Filter != nil && s.Filter.RespectGitignore {
+			dir.ignore = LoadIgnoreFiles(dir.path, dir.rel, dir.ignore)
+		}
+		for _, entry := range entries {
+			path := filepath.Join(dir.path, entry.Name())
+			rel := entry.Name()
+			if dir.rel != "" {
+				rel = dir.rel + "/" + rel
+			}
+			if s.Filter != nil && s.Filter.Skip(rel, entry.IsDir(), dir.ignore) {
+				continue
+			}
+
+			var info os.FileInfo
+			isDir := entry.IsDir()
+			if entry.Type()&fs.ModeSymlink != 0 {
+				if !s.Opts.FollowSymlinks {
+					s.skip(path, SkipSymlink)
+					continue
+				}
+				target, err := os.Stat(path)
+				if err != nil {
+					s.report(path, "follow", err)
+					continue
+				}
+				info = target
+				isDir = target.IsDir()
+			}
+
+			if !s.enter(dir.root, path, entry, info, isDir) {
+				continue
+			}
+			if isDir {
+				s.pushDir(scanDir{root: dir.root, path: path, rel: rel, ignore: dir.ignore})
+			} else if !s.isStopped() {
+				items <- scanItem{root: dir.root, path: path, entry: entry, info: info}
+			}
+		}
+		s.doneDir()
+	}
+}
+
+// enter decides whether to scan a path found in a directory: nothing on another filesystem with --one-file-system,
+// and no directory already scanned when following symlinks could lead back to it. info is the symlink target's when
+// following one.
+func (s *Scanner) enter(root string, path string, entry fs.DirEntry, info os.FileInfo, isDir bool) bool {
+	if !s.Opts.OneFileSystem && !(s.Opts.FollowSymlinks && isDir) {
+		return true
+	}
+	if info == nil {
+		if !isDir {
+			// a plain file is on the filesystem of its directory
+			return true
+		}
+		var err error
+		info, err = entry.Info()
+		if err != nil {
+			s.report(path, "stat", err)
+			return false
+		}
+	}
+	id, _, _, ok := inodeOf(info)
+	if !ok {
+		return true
+	}
+
+	if s.Opts.OneFileSystem && id.dev != s.rootDevs[root] {
+		s.skip(path, SkipMount)
+		return false
+	}
+	if s.Opts.FollowSymlinks && isDir {
+		s.lmu.Lock()
+		seen := s.dirsSeen[id]
+		s.dirsSeen[id] = true
+		s.lmu.Unlock()
+		if seen {
+			s.skip(path, SkipLoop)
+			return false
+		}
+	}
+	return true
+}
+
+// skip hands a path passed over to Skip.
+func (s *Scanner) skip(path string, kind string) {
+	if s.Skip != nil {
+		s.Locked(func() {
+			s.Skip(path, kind)
+		})
+	}
+}
+
+// nextDir blocks until a directory is available or there is nothing left to read.
+func (s *Scanner) nextDir() (scanDir, bool) {
+	s.qmu.Lock()
+	defer s.qmu.Unlock()
+	for len(s.dirs) == 0 && s.pending > 0 && !s.stopped {
+		s.qcond.Wait()
+	}
+	if len(s.dirs) == 0 || s.stopped {
+		return scanDir{}, false
+	}
+	// depth first keeps the queue short on wide trees
+	last := len(s.dirs) - 1
+	dir := s.dirs[last]
+	s.dirs = s.dirs[:last]
+	return dir, true
+}
+
+// pushDir queues a directory for reading.
+func (s *Scanner) pushDir(dir scanDir) {
+	s.qmu.Lock()
+	s.dirs = append(s.dirs, dir)
+	s.pending++
+	s.qmu.Unlock()
+	s.qcond.Signal()
+}
+
+// doneDir marks a directory as read, waking idle readers when the whole tree has been read.
+func (s *Scanner) doneDir() {
+	s.qmu.Lock()
+	s.pending--
+	finished := s.pending == 0
+	s.qmu.Unlock()
+	if finished {
+		s.qcond.Broadcast()
+	}
+}
+
+// report hands an error to Error, or stops the scan when errors are fatal.
+func (s *Scanner) report(path string, op string, err error) {
+	serr := NewScanError(path, op, err)
+	if s.Error != nil {
+		s.Locked(func() {
+			s.Error(serr)
+		})
+	}
+	if s.Error == nil || s.Opts.FailOnError {
+		s.fail(serr)
+	}
+}
+
+// fail records the first error encountered and stops the scan.
+func (s *Scanner) fail(err error) {
+	s.qmu.Lock()
+	if s.err == nil {
+		s.err = err
+	}
+	s.stopped = true
+	s.qmu.Unlock()
+	s.qcond.Broadcast()
+}
+
+// Stop abandons the scan, Run returns once the files already handed to the workers have been visited.
+func (s *Scanner) Stop() {
+	s.fail(nil)
+}
+
+// isStopped reports whether the scan has been stopped by an error or Stop.
+func (s *Scanner) isStopped() bool {
+	s.qmu.Lock()
+	defer s.qmu.Unlock()
+	return s.stopped
+}
+
+// work does the expensive per file work (stat, line counting, hashing) outside the lock, then visits the record.
+func (s *Scanner) work(item scanItem) {
+	if s.isStopped() {
+		return
+	}
+
+	rec := &FileRecord{Root: item.root, Path: item.path, Info: item.info}
+	if rec.Info == nil {
+		info, err := item.entry.Info()
+		if err != nil {
+			s.report(item.path, "stat", err)
+			return
+		}
+		rec.Info = info
+	}
+	rec.Allocated, rec.Linked = s.account(rec.Info)
+	rec.Time = FileTime(rec.Path, rec.Info, s.Opts.TimeField)
+	if s.Languages != nil {
+		rec.Language = s.Languages.Detect(rec.Path, rec.Info)
+	}
+	if s.Owners != nil {
+		rec.Owner, rec.OwnerGroup = s.Owners.Lookup(rec.Info)
+	}
+
+	// only regular files have content worth reading, opening a fifo would block the worker
+	if s.Opts.Sloc && rec.Info.Mode().IsRegular() && !rec.Linked {
+		stats, err := CountSloc(rec.Path, rec.Language)
+		if err != nil {
+			s.report(rec.Path, "read", err)
+			rec.Err = err
+		}
+		rec.Sloc = stats
+		rec.Lines = stats.Total()
+	} else if s.Opts.Lines && rec.Info.Mode().IsRegular() && !rec.Linked {
+		lines, err := CountLines(rec.Path)
+		if err != nil {
+			s.report(rec.Path, "read", err)
+			rec.Err = err
+		}
+		rec.Lines = lines
+	}
+
+	if s.Opts.Hash != "" && rec.Info.Mode().IsRegular() && rec.Err == nil {
+		sum, err := HashFile(rec.Path, s.Opts.Hash)
+		if err != nil {
+			s.report(rec.Path, "hash", err)
+			rec.Err = err
+		}
+		rec.Hash = sum
+	}
+	if rec.Err != nil && s.isStopped() {
+		return
+	}
+	if s.hold(rec) {
+		return
+	}
+
+	s.Locked(func() {
+		s.Visit(rec)
+	})
+}
+
+// hold keeps back the record of a file with several hard links until every link has been found, then visits them
+// all. It returns false for any other file. Links outside the scanned trees are never found, those inodes wait for
+// the end of the scan.
+func (s *Scanner) hold(rec *FileRecord) bool {
+	id, nlink, _, ok := inodeOf(rec.Info)
+	if !ok || nlink < 2 || !rec.Info.Mode().IsRegular() {
+		return false
+	}
+	s.lmu.Lock()
+	links := append(s.held[id], rec)
+	if uint64(len(links)) < nlink {
+		s.held[id] = links
+		links = nil
+	} else {
+		delete(s.held, id)
+	}
+	s.lmu.Unlock()
+
+	if links != nil {
+		s.settleLinks(links)
+		s.Locked(func() {
+			for _, link := range links {
+				s.Visit(link)
+			}
+		})
+	}
+	return true
+}
+
+// settleLinks sorts the links of an inode by path and makes the lexically smallest the owner of its bytes and lines,
+// so which extension, directory or owner gets them doesn't depend on which worker reached the inode first. The
+// content was only read for the first link reached, its counts move to the owner.
+func (s *Scanner) settleLinks(links []*FileRecord) {
+	sort.Slice(links, func(i, j int) bool { return links[i].Path < links[j].Path })
+	owner := links[0]
+	for _, rec := range links[1:] {
+		if rec.Linked {
+			continue
+		}
+		owner.Lines, owner.Sloc, owner.Err = rec.Lines, rec.Sloc, rec.Err
+		rec.Lines, rec.Sloc, rec.Err = 0, LineStats{}, nil
+		rec.Linked, owner.Linked = true, false
+		// the comment syntax goes by name, the owner's may read the same content differently
+		if s.Opts.Sloc && owner.Err == nil && syntaxFor(owner.Path, owner.Language) != syntaxFor(rec.Path, rec.Language) {
+			owner.Sloc, owner.Err = CountSloc(owner.Path, owner.Language)
+			if owner.Err != nil {
+				s.report(owner.Path, "read", owner.Err)
+			}
+			owner.Lines = owner.Sloc.Total()
+		}
+	}
+}
+
+// visitLinks visits the files still held back at the end of the scan, those with links outside the scanned trees.
+func (s *Scanner) visitLinks() {
+	var recs []*FileRecord
+	for _, links := range s.held {
+		s.settleLinks(links)
+		recs = append(recs, links...)
+	}
+	s.held = make(map[inodeKey][]*FileRecord)
+	sort.Slice(recs, func(i, j int) bool { return recs[i].Path < recs[j].Path })
+	s.Locked(func() {
+		for _, rec := range recs {
+			s.Visit(rec)
+		}
+	})
+}
+
+// account works out the bytes a file takes on disk and whether it is a further hard link to a file already seen.
+// Only files with more than one link are remembered, unless following symlinks can reach any file twice.
+func (s *Scanner) account(info os.FileInfo) (uint64, bool) {
+	id, nlink, allocated, ok := inodeOf(info)
+	if !ok {
+		return uint64(info.Size()), false
+	}
+	if (nlink < 2 && !s.Opts.FollowSymlinks) || !info.Mode().IsRegular() {
+		return allocated, false
+	}
+
+	s.lmu.Lock()
+	defer s.lmu.Unlock()
+	if s.links[id] {
+		return allocated, true
+	}
+	s.links[id] = true
+	return allocated, false
+}
+
+// refreshLoop periodically calls Refresh with the lock held until done is closed.
+func (s *Scanner) refreshLoop(done <-chan struct{}, refreshed chan<- struct{}) {
+	defer close(refreshed)
+	if s.Refresh == nil {
+		return
+	}
+
+	ticker := time.NewTicker(s.Interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-done:
+			return
+		case <-ticker.C:
+			s.Locked(s.Refresh)
+		}
+	}
+}
@@ -0,0 +1,333 @@
+// core package contains all the components needed by the summarizefiles utility.
+package core
+
+import (
+	"bufio"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// LineStats type splits the lines of a file, or of many, into code, comment and blank lines. A line holding both
+// code and a comment counts as code.
+type LineStats struct {
+	Code    int `json:"code_lines"`
+	Comment int `json:"comment_lines"`
+	Blank   int `json:"blank_lines"`
+}
+
+// Total is the number of lines classified.
+func (ls LineStats) Total() int {
+	return ls.Code + ls.Comment + ls.Blank
+}
+
+// Add adds the lines of other to ls.
+func (ls *LineStats) Add(other LineStats) {
+	ls.Code += other.Code
+	ls.Comment += other.Comment
+	ls.Blank += other.Blank
+}
+
+// commentSyntax type is how a language marks comments: markers running to the end of the line, pairs of markers
+// opening and closing a block, and the characters quoting strings, inside which markers don't count. With leading
+// set, a block marker only opens a comment as the first token of a line, elsewhere it opens a string running to the
+// closing marker, as python's triple quotes do. words are line markers that are words, matched whatever their case
+// and only standing alone, as batch's rem.
+type commentSyntax struct {
+	line    []string
+	words   []string
+	block   [][2]string
+	quotes  string
+	leading bool
+}
+
+var (
+	cSyntax      = &commentSyntax{line: []string{"//"}, block: [][2]string{{"/*", "*/"}}, quotes: "\"'`"}
+	rustSyntax   = &commentSyntax{line: []string{"//"}, block: [][2]string{{"/*", "*/"}}, quotes: "\""}
+	cssSyntax    = &commentSyntax{block: [][2]string{{"/*", "*/"}}, quotes: "\"'"}
+	phpSyntax    = &commentSyntax{line: []string{"//", "#"}, block: [][2]string{{"/*", "*/"}}, quotes: "\"'"}
+	hashSyntax   = &commentSyntax{line: []string{"#"}, quotes: "\"'"}
+	pythonSyntax = &commentSyntax{line: []string{"#"}, block: [][2]string{{`"""`, `"""`}, {"'''", "'''"}}, quotes: "\"'",
+		leading: true}
+	rubySyntax   = &commentSyntax{line: []string{"#"}, block: [][2]string{{"=begin", "=end"}}, quotes: "\"'"}
+	iniSyntax    = &commentSyntax{line: []string{"#", ";"}, quotes: "\""}
+	sqlSyntax    = &commentSyntax{line: []string{"--"}, block: [][2]string{{"/*", "*/"}}, quotes: "\"'"}
+	luaSyntax    = &commentSyntax{line: []string{"--"}, block: [][2]string{{"--[[", "]]"}}, quotes: "\"'"}
+	haskell      = &commentSyntax{line: []string{"--"}, block: [][2]string{{"{-", "-}"}}, quotes: "\""}
+	markupSyntax = &commentSyntax{block: [][2]string{{"<!--", "-->"}}}
+	lispSyntax   = &commentSyntax{line: []string{";"}, block: [][2]string{{"#|", "|#"}}, quotes: "\""}
+	erlangSyntax = &commentSyntax{line: []string{"%"}, quotes: "\""}
+	asmSyntax    = &commentSyntax{line: []string{"//", "#", ";"}, block: [][2]string{{"/*", "*/"}}, quotes: "\"'"}
+	fortran      = &commentSyntax{line: []string{"!"}, quotes: "\"'"}
+	vimSyntax    = &commentSyntax{line: []string{"\""}, quotes: "'"}
+	batchSyntax  = &commentSyntax{line: []string{"::"}, words: []string{"rem"}, quotes: "\""}
+)
+
+// commentSyntaxes maps a lower case file extension to the comment syntax of its language.
+var commentSyntaxes = map[string]*commentSyntax{
+	"c": cSyntax, "h": cSyntax, "cc": cSyntax, "cpp": cSyntax, "cxx": cSyntax, "hpp": cSyntax, "hh": cSyntax,
+	"m": cSyntax, "mm": cSyntax, "cs": cSyntax, "go": cSyntax, "java": cSyntax, "kt": cSyntax, "kts": cSyntax,
+	"scala": cSyntax, "groovy": cSyntax, "gradle": cSyntax, "swift": cSyntax, "dart": cSyntax, "js": cSyntax,
+	"mjs": cSyntax, "cjs": cSyntax, "jsx": cSyntax, "ts": cSyntax, "tsx": cSyntax, "proto": cSyntax,
+	"zig": cSyntax, "v": cSyntax, "sv": cSyntax, "scss": cSyntax, "less": cSyntax, "jsonc": cSyntax,
+	"rs":  rustSyntax,
+	"css": cssSyntax,
+	"php": phpSyntax,
+	"sh":  hashSyntax, "bash": hashSyntax, "zsh": hashSyntax, "ksh": hashSyntax, "fish": hashSyntax,
+	"pl": hashSyntax, "pm": hashSyntax, "r": hashSyntax, "tcl": hashSyntax, "awk": hashSyntax, "mk": hashSyntax,
+	"cmake": hashSyntax, "yaml": hashSyntax, "yml": hashSyntax, "toml": hashSyntax, "nim": hashSyntax,
+	"ex": hashSyntax, "exs": hashSyntax, "jl": hashSyntax, "ps1": hashSyntax, "tf": hashSyntax,
+	"py": pythonSyntax, "pyi": pythonSyntax, "pyw": pythonSyntax,
+	"rb":  rubySyntax,
+	"ini": iniSyntax, "cfg": iniSyntax, "conf": iniSyntax, "properties": iniSyntax,
+	"sql": sqlSyntax,
+	"lua": luaSyntax,
+	"hs":  haskell, "elm": haskell,
+	"html": markupSyntax, "htm": markupSyntax, "xml": markupSyntax, "xhtml": markupSyntax, "svg": markupSyntax,
+	"xsl": markupSyntax, "xslt": markupSyntax, "vue": markupSyntax,
+	"lisp": lispSyntax, "lsp": lispSyntax, "el": lispSyntax, "scm": lispSyntax, "ss": lispSyntax, "rkt": lispSyntax,
+	"clj": lispSyntax, "cljs": lispSyntax, "edn": lispSyntax,
+	"asm": asmSyntax, "s": asmSyntax, "nasm": asmSyntax,
+	"erl": erlangSyntax, "hrl": erlangSyntax, "tex": erlangSyntax, "sty": erlangSyntax,
+	"f90": fortran, "f95": fortran, "f03": fortran,
+	"vim": vimSyntax,
+	"bat": batchSyntax, "cmd": batchSyntax,
+}
+
+// commentSyntaxNames maps the lower case names of files that don't go by extension to their comment syntax.
+var commentSyntaxNames = map[string]*commentSyntax{
+	"makefile":       hashSyntax,
+	"gnumakefile":    hashSyntax,
+	"dockerfile":     hashSyntax,
+	"cmakelists.txt": hashSyntax,
+	"vagrantfile":    rubySyntax,
+	"gemfile":        rubySyntax,
+	"rakefile":       rubySyntax,
+	".vimrc":         vimSyntax,
+}
+
+// langSyntaxes maps the lower case names of the scripting languages --lang recognizes by shebang to their comment
+// syntax, for scripts whose name says nothing.
+var langSyntaxes = map[string]*commentSyntax{
+	"shell": hashSyntax, "python": pythonSyntax, "ruby": rubySyntax, "perl": hashSyntax, "php": phpSyntax,
+	"javascript": cSyntax, "typescript": cSyntax, "lua": luaSyntax, "tcl": hashSyntax, "awk": hashSyntax,
+	"r": hashSyntax, "julia": hashSyntax, "elixir": hashSyntax, "makefile": hashSyntax, "groovy": cSyntax,
+	"scala": cSyntax, "swift": cSyntax, "powershell": hashSyntax, "assembly": asmSyntax,
+}
+
+// plainSyntax : the syntax of files in no known language. Nothing is a comment, every line with text counts as code.
+var plainSyntax = &commentSyntax{}
+
+// syntaxFor picks the comment syntax for a file by its name, then its extension, then the language --lang found.
+func syntaxFor(path string, lang string) *commentSyntax {
+	name := strings.ToLower(filepath.Base(path))
+	if cs, ok := commentSyntaxNames[name]; ok {
+		return cs
+	}
+	if cs, ok := commentSyntaxes[strings.TrimPrefix(filepath.Ext(name), ".")]; ok {
+		return cs
+	}
+	if cs, ok := langSyntaxes[strings.ToLower(lang)]; ok {
+		return cs
+	}
+	return plainSyntax
+}
+
+// CountSloc given a file path, decide if the file is a text file and count its code, comment and blank lines using
+// the comment syntax of the language the file name points at, or lang, the language --lang detected. Like
+// CountLines it is called concurrently by the scan workers. Strings are only followed to the end of the line, so the
+// counts are a close estimate rather than a parse.
+func CountSloc(path string, lang string) (LineStats, error) {
+	mimetype, err := MimeTypeFromFile(path)
+	if err != nil {
+		return LineStats{}, err
+	}
+	if !IsTextType(mimetype) {
+		return LineStats{}, nil
+	}
+
+	inf, err := os.Open(path)
+	if err != nil {
+		return LineStats{}, err
+	}
+	defer inf.Close()
+	return slocCounter(inf, syntaxFor(path, lang))
+}
+
+// slocCounter do the core task of classifying the lines read from r.
+func slocCounter(r io.Reader, cs *commentSyntax) (LineStats, error) {
+	lc := &lineClassifier{syntax: cs, block: -1, long: -1}
+	err := readLines(r, lc.feed, lc.endLine)
+	return lc.stats, err
+}
+
+// readLines reads r line by line for --sloc. feed is given every line, in several chunks when it is longer than the
+// buffer, and endLine is called once each line is complete. A last line without a newline is a line too, it holds
+// code or comments all the same.
+func readLines(r io.Reader, feed func(chunk []byte), endLine func()) error {
+	rd := bufio.NewReaderSize(r, 32*1024)
+	pending := false
+	for {
+		chunk, err := rd.ReadSlice('\n')
+		if len(chunk) > 0 {
+			feed(chunk)
+			pending = true
+		}
+		if err == bufio.ErrBufferFull {
+			// a line longer than the buffer, carry on with the rest of it
+			continue
+		}
+		if pending {
+			endLine()
+			pending = false
+		}
+
+		if err == io.EOF {
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+	}
+}
+
+// lineClassifier type follows the comments and strings of a file line by line. A line may be fed in several chunks.
+type lineClassifier struct {
+	syntax *commentSyntax
+	stats  LineStats
+
+	// block is the index of the block comment open, -1 when none is
+	block int
+	// long is the index of the block pair quoting a string over several lines, -1 when none is open
+	long int
+	// quote is the quote of the string open, zero when none is
+	quote   byte
+	escaped bool
+	// rest marks a line comment running to the end of the line
+	rest    bool
+	code    bool
+	comment bool
+}
+
+// feed classifies part of a line.
+func (lc *lineClassifier) feed(chunk []byte) {
+	cs := lc.syntax
+	for idx := 0; idx < len(chunk); {
+		ch := chunk[idx]
+		switch {
+		case lc.rest:
+			return
+		case lc.block >= 0:
+			// blank lines inside a block comment stay blank
+			if !isBlankByte(ch) {
+				lc.comment = true
+			}
+			if closing := cs.block[lc.block][1]; hasPrefixAt(chunk, idx, closing) {
+				lc.block = -1
+				idx += len(closing)
+				continue
+			}
+		case lc.long >= 0:
+			if !isBlankByte(ch) {
+				lc.code = true
+			}
+			if closing := cs.block[lc.long][1]; hasPrefixAt(chunk, idx, closing) {
+				lc.long = -1
+				idx += len(closing)
+				continue
+			}
+		case lc.quote != 0:
+			lc.code = true
+			if lc.escaped {
+				lc.escaped = false
+			} else if ch == '\\' {
+				lc.escaped = true
+			} else if ch == lc.quote {
+				lc.quote = 0
+			}
+		case isBlankByte(ch):
+		default:
+			// skipping the whole opening marker keeps it from being taken for the closing one, as with python's """
+			if marker := lc.startsComment(chunk, idx); marker > 0 {
+				idx += marker
+				continue
+			}
+			lc.code = true
+			if strings.IndexByte(cs.quotes, ch) >= 0 {
+				lc.quote = ch
+			}
+		}
+		idx++
+	}
+}
+
+// startsComment checks for a comment marker at chunk[idx], opening the comment it starts. It returns the length of
+// the marker, zero when there is none. Block comments are checked first, Lua's "--[[" would otherwise read as a line
+// comment.
+func (lc *lineClassifier) startsComment(chunk []byte, idx int) int {
+	cs := lc.syntax
+	for bidx, pair := range cs.block {
+		if hasPrefixAt(chunk, idx, pair[0]) && cs.leading && (lc.code || lc.comment) {
+			// not the first token of the line, a string rather than a comment
+			lc.code = true
+			lc.long = bidx
+			return len(pair[0])
+		}
+		if hasPrefixAt(chunk, idx, pair[0]) {
+			lc.comment = true
+			lc.block = bidx
+			return len(pair[0])
+		}
+	}
+	for _, marker := range cs.line {
+		if hasPrefixAt(chunk, idx, marker) {
+			lc.comment = true
+			lc.rest = true
+			return len(marker)
+		}
+	}
+	for _, word := range cs.words {
+		if hasWordAt(chunk, idx, word) {
+			lc.comment = true
+			lc.rest = true
+			return len(word)
+		}
+	}
+	return 0
+}
+
+// endLine counts the line fed so far. Block comments and long strings carry on to the next line, other strings and
+// line comments don't.
+func (lc *lineClassifier) endLine() {
+	switch {
+	case lc.code:
+		lc.stats.Code++
+	case lc.comment:
+		lc.stats.Comment++
+	default:
+		lc.stats.Blank++
+	}
+	lc.code, lc.comment, lc.rest = false, false, false
+	lc.quote, lc.escaped = 0, false
+}
+
+// hasPrefixAt reports whether chunk holds prefix at idx.
+func hasPrefixAt(chunk []byte, idx int, prefix string) bool {
+	return len(chunk)-idx >= len(prefix) && string(chunk[idx:idx+len(prefix)]) == prefix
+}
+
+// hasWordAt reports whether chunk holds word at idx in any case, with white space or the end of the line on both
+// sides.
+func hasWordAt(chunk []byte, idx int, word string) bool {
+	end := idx + len(word)
+	if len(chunk) < end || !strings.EqualFold(string(chunk[idx:end]), word) {
+		return false
+	}
+	return (idx == 0 || isBlankByte(chunk[idx-1])) && (end == len(chunk) || isBlankByte(chunk[end]))
+}
+
+// isBlankByte reports whether ch is white space.
+func isBlankByte(ch byte) bool {
+	return ch == ' ' || ch == '\t' || ch == '\r' || ch == '\n' || ch == '\f' || ch == '\v'
+}
@@ -0,0 +1,92 @@
+// core package contains all the components needed by the summarizefiles utility.
+package core
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"time"
+)
+
+// SnapshotVersion : bumped whenever the snapshot layout changes incompatibly.
+const SnapshotVersion = 1
+
+// Snapshot type is a scan persisted to disk so it can be diffed against a later scan.
+type Snapshot struct {
+	Version int       `json:"version"`
+	Created time.Time `json:"created"`
+	// AsOf is the --as-of time ages were measured from, nil when they were measured from Created.
+	AsOf    *time.Time   `json:"as_of,omitempty"`
+	Mode    string       `json:"mode"`
+	Lines   bool         `json:"lines"`
+	Summary *FileSummary `json:"summary"`
+}
+
+// NewSnapshot construct a Snapshot instance for a finished scan.
+func NewSnapshot(opts *ProgramOpts, summ *FileSummary) Snapshot {
+	snap := Snapshot{}
+	snap.Version = SnapshotVersion
+	snap.Created = time.Now()
+	if opts.AsOf != "" {
+		asOf := opts.Now()
+		snap.AsOf = &asOf
+	}
+	snap.Mode = opts.Mode()
+	snap.Lines = opts.Lines
+	snap.Summary = summ
+	return snap
+}
+
+// Label names the snapshot in a diff: its creation date, and the --as-of date when it was taken with one.
+func (snap *Snapshot) Label() string {
+	label := snap.Created.Format(DateOnly)
+	if snap.AsOf != nil {
+		label += " as of " + snap.AsOf.Format(DateOnly)
+	}
+	return label
+}
+
+// WriteSnapshot saves a scan to path as JSON.
+func WriteSnapshot(path string, opts *ProgramOpts, summ *FileSummary) error {
+	f, err := os.Create(path)
+	if err != nil {
+		return err
+	}
+
+	enc := json.NewEncoder(f)
+	enc.SetIndent("", "  ")
+	err = enc.Encode(NewSnapshot(opts, summ))
+	if err != nil {
+		f.Close()
+		return err
+	}
+	return f.Close()
+}
+
+// ReadSnapshot loads a scan saved by WriteSnapshot.
+func ReadSnapshot(path string) (*Snapshot, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	snap := &Snapshot{}
+	err = json.NewDecoder(f).Decode(snap)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", path, err)
+	}
+	if snap.Version != SnapshotVersion {
+		return nil, fmt.Errorf("%s: unsupported snapshot version %d", path, snap.Version)
+	}
+	if snap.Summary == nil {
+		return nil, fmt.Errorf("%s: snapshot has no summary", path)
+	}
+	if snap.Summary.Entries == nil {
+		snap.Summary.Entries = NewSummaryEntryMap()
+	}
+	if snap.Summary.Groups == nil {
+		snap.Summary.Groups = NewGroupMap()
+	}
+	return snap, nil
+}
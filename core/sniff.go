@@ -0,0 +1,247 @@
+// core package contains all the components needed by the summarizefiles utility.
+package core
+
+import (
+	"bytes"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+	"unicode/utf8"
+)
+
+// sniffLen : how much of a file the content sniffer examines.
+const sniffLen = 8 * 1024
+
+const (
+	MimeEmpty  = "inode/x-empty"
+	MimeText   = "text/plain"
+	MimeBinary = "application/octet-stream"
+)
+
+// byteOrderMarks maps the byte order marks recognized by the sniffer to the text encoding they announce.
+var byteOrderMarks = []struct {
+	mark     []byte
+	encoding string
+}{
+	// UTF-32 must be tested before UTF-16, the little endian marks share a prefix.
+	{[]byte{0xFF, 0xFE, 0x00, 0x00}, "utf-32le"},
+	{[]byte{0x00, 0x00, 0xFE, 0xFF}, "utf-32be"},
+	{[]byte{0xEF, 0xBB, 0xBF}, "utf-8"},
+	{[]byte{0xFF, 0xFE}, "utf-16le"},
+	{[]byte{0xFE, 0xFF}, "utf-16be"},
+}
+
+// binarySignatures are the leading bytes of common binary formats, used to name binary content.
+var binarySignatures = []struct {
+	sig      []byte
+	mimetype string
+}{
+	{[]byte("\x7fELF"), "application/x-executable"},
+	{[]byte("\x89PNG\r\n\x1a\n"), "image/png"},
+	{[]byte("GIF87a"), "image/gif"},
+	{[]byte("GIF89a"), "image/gif"},
+	{[]byte{0xFF, 0xD8, 0xFF}, "image/jpeg"},
+	{[]byte("%PDF-"), "application/pdf"},
+	{[]byte("PK\x03\x04"), "application/zip"},
+	{[]byte{0x1F, 0x8B}, "application/gzip"},
+	{[]byte("BZh"), "application/x-bzip2"},
+	{[]byte{0xFD, '7', 'z', 'X', 'Z', 0x00}, "application/x-xz"},
+	{[]byte{0x28, 0xB5, 0x2F, 0xFD}, "application/zstd"},
+	{[]byte{'7', 'z', 0xBC, 0xAF, 0x27, 0x1C}, "application/x-7z-compressed"},
+	{[]byte("SQLite format 3\x00"), "application/vnd.sqlite3"},
+	{[]byte("!<arch>\n"), "application/x-archive"},
+	{[]byte{0xCA, 0xFE, 0xBA, 0xBE}, "application/x-java-applet"},
+}
+
+// extensionTypes maps a lower case file extension to the MIME-like type reported for it. The table only names the
+// content, the sniffer still decides whether the content is text or binary.
+var extensionTypes = map[string]string{
+	"c":     "text/x-c",
+	"h":     "text/x-c",
+	"cc":    "text/x-c++",
+	"cpp":   "text/x-c++",
+	"cxx":   "text/x-c++",
+	"hpp":   "text/x-c++",
+	"cs":    "text/x-csharp",
+	"go":    "text/x-go",
+	"rs":    "text/x-rust",
+	"java":  "text/x-java",
+	"js":    "text/javascript",
+	"mjs":   "text/javascript",
+	"cjs":   "text/javascript",
+	"ts":    "text/x-typescript",
+	"py":    "text/x-python",
+	"rb":    "text/x-ruby",
+	"pl":    "text/x-perl",
+	"sh":    "text/x-shellscript",
+	"bash":  "text/x-shellscript",
+	"el":    "text/x-lisp",
+	"lisp":  "text/x-lisp",
+	"sql":   "text/x-sql",
+	"html":  "text/html",
+	"htm":   "text/html",
+	"xml":   "text/xml",
+	"css":   "text/css",
+	"md":    "text/markdown",
+	"txt":   "text/plain",
+	"csv":   "text/csv",
+	"json":  "application/json",
+	"yaml":  "text/x-yaml",
+	"yml":   "text/x-yaml",
+	"toml":  "text/x-toml",
+	"ini":   "text/plain",
+	"svg":   "image/svg+xml",
+	"png":   "image/png",
+	"gif":   "image/gif",
+	"jpg":   "image/jpeg",
+	"jpeg":  "image/jpeg",
+	"pdf":   "application/pdf",
+	"zip":   "application/zip",
+	"jar":   "application/java-archive",
+	"gz":    "application/gzip",
+	"tgz":   "application/gzip",
+	"bz2":   "application/x-bzip2",
+	"xz":    "application/x-xz",
+	"zst":   "application/zstd",
+	"7z":    "application/x-7z-compressed",
+	"so":    "application/x-sharedlib",
+	"a":     "application/x-archive",
+	"o":     "application/x-object",
+	"exe":   "application/x-dosexec",
+	"class": "application/x-java-applet",
+	"db":    "application/vnd.sqlite3",
+}
+
+// SniffFile reads the first block of the file at path and decides what kind of content it holds.
+func SniffFile(path string) (string, error) {
+	inf, err := os.Open(path)
+	if err != nil {
+		return "", err
+	}
+	defer inf.Close()
+
+	buf := make([]byte, sniffLen)
+	n, err := io.ReadFull(inf, buf)
+	if err != nil && err != io.EOF && err != io.ErrUnexpectedEOF {
+		return "", err
+	}
+
+	return SniffContent(filepath.Base(path), buf[:n]), nil
+}
+
+// SniffContent reports a MIME-like type for a file named name whose leading bytes are head. The content decides
+// text vs. binary: byte order marks, NUL bytes, the share of control characters and UTF-8 validity are examined in
+// that order. The extension table is then consulted to give the content a more specific name.
+func SniffContent(name string, head []byte) string {
+	if len(head) == 0 {
+		return MimeEmpty
+	}
+
+	exttype := extensionTypes[strings.ToLower(strings.TrimPrefix(filepath.Ext(name), "."))]
+
+	for _, bom := range byteOrderMarks {
+		if bytes.HasPrefix(head, bom.mark) {
+			return textType(exttype)
+		}
+	}
+
+	if !IsText(head) {
+		for _, bs := range binarySignatures {
+			if bytes.HasPrefix(head, bs.sig) {
+				return bs.mimetype
+			}
+		}
+		if exttype != "" && !IsTextType(exttype) {
+			return exttype
+		}
+		return MimeBinary
+	}
+
+	return textType(exttype)
+}
+
+// IsText applies the text heuristics to a block of content that carries no byte order mark.
+func IsText(head []byte) bool {
+	if bytes.IndexByte(head, 0) >= 0 {
+		return false
+	}
+
+	controls := 0
+	for _, b := range head {
+		if b < 0x20 || b == 0x7F {
+			switch b {
+			case '\t', '\n', '\r', '\f', '\v', '\b', 0x1B:
+				// whitespace, backspace and escape sequences show up in plain text and terminal logs
+			default:
+				controls++
+			}
+		}
+	}
+	// tolerate the occasional stray control character, but not a block full of them
+	if controls*100 > len(head) {
+		return false
+	}
+
+	if utf8.Valid(head) {
+		return true
+	}
+	// the block may end part way through a multibyte sequence
+	for trim := 1; trim < utf8.UTFMax && trim < len(head); trim++ {
+		if utf8.Valid(head[:len(head)-trim]) {
+			return true
+		}
+	}
+
+	// not UTF-8, treat it as a legacy 8 bit encoding unless high bytes dominate the block
+	high := 0
+	for _, b := range head {
+		if b >= 0x80 {
+			high++
+		}
+	}
+	return high*100 < len(head)*30
+}
+
+// textApplicationTypes : the types outside text/ that name text content, as libmagic reports scripts and data formats.
+var textApplicationTypes = map[string]bool{
+	"application/json":          true,
+	"application/javascript":    true,
+	"application/ecmascript":    true,
+	"application/xml":           true,
+	"application/sql":           true,
+	"application/toml":          true,
+	"application/x-yaml":        true,
+	"application/x-sh":          true,
+	"application/x-shellscript": true,
+	"application/x-awk":         true,
+	"application/x-perl":        true,
+	"application/x-ruby":        true,
+	"application/x-php":         true,
+	"application/x-tcl":         true,
+	"application/x-ndjson":      true,
+}
+
+// IsTextType reports whether a MIME-like type describes text content. Both the pure-Go sniffer and the libmagic build
+// decide with it, so they agree on what counts as text. Parameters such as "; charset=utf-8" are ignored.
+func IsTextType(mimetype string) bool {
+	if idx := strings.IndexByte(mimetype, ';'); idx >= 0 {
+		mimetype = mimetype[:idx]
+	}
+	mimetype = strings.ToLower(strings.TrimSpace(mimetype))
+	switch {
+	case strings.HasPrefix(mimetype, "text/"):
+		return true
+	case strings.HasSuffix(mimetype, "+xml"), strings.HasSuffix(mimetype, "+json"):
+		return true
+	}
+	return textApplicationTypes[mimetype]
+}
+
+// textType picks the name for text content, falling back to text/plain when the extension doesn't say.
+func textType(exttype string) string {
+	if exttype != "" && IsTextType(exttype) {
+		return exttype
+	}
+	return MimeText
+}
@@ -0,0 +1,29 @@
+//go:build linux || darwin
+
+// core package contains all the components needed by the summarizefiles utility.
+package core
+
+import (
+	"os"
+	"syscall"
+)
+
+// inodeOf reads the device and inode identifying a file, its hard link count and the bytes allocated to it on disk.
+// ok is false when the platform's stat doesn't say.
+func inodeOf(info os.FileInfo) (id inodeKey, nlink uint64, allocated uint64, ok bool) {
+	st, ok := info.Sys().(*syscall.Stat_t)
+	if !ok {
+		return id, 0, 0, false
+	}
+	// st_blocks counts 512 byte units whatever the filesystem block size
+	return inodeKey{dev: uint64(st.Dev), ino: uint64(st.Ino)}, uint64(st.Nlink), uint64(st.Blocks) * 512, true
+}
+
+// ownerOf reads the user and group ids owning a file. ok is false when the platform's stat doesn't say.
+func ownerOf(info os.FileInfo) (uid uint32, gid uint32, ok bool) {
+	st, ok := info.Sys().(*syscall.Stat_t)
+	if !ok {
+		return 0, 0, false
+	}
+	return uint32(st.Uid), uint32(st.Gid), true
+}
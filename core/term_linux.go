@@ -0,0 +1,49 @@
+//go:build linux
+
+// core package contains all the components needed by the summarizefiles utility.
+package core
+
+import (
+	"syscall"
+	"unsafe"
+)
+
+// termState type is the terminal settings to put back when leaving raw mode.
+type termState struct {
+	termios syscall.Termios
+}
+
+// ioctlTermios gets or sets the terminal settings of fd.
+func ioctlTermios(fd uintptr, req uintptr, t *syscall.Termios) error {
+	if _, _, err := syscall.Syscall6(syscall.SYS_IOCTL, fd, req, uintptr(unsafe.Pointer(t)), 0, 0, 0); err != 0 {
+		return err
+	}
+	return nil
+}
+
+// makeRaw puts the terminal on fd into raw mode: keys arrive one at a time, unechoed, and ^C is just a key. Returns
+// the previous settings for restoreTerm.
+func makeRaw(fd uintptr) (*termState, error) {
+	var old syscall.Termios
+	if err := ioctlTermios(fd, syscall.TCGETS, &old); err != nil {
+		return nil, err
+	}
+
+	raw := old
+	raw.Iflag &^= syscall.IGNBRK | syscall.BRKINT | syscall.PARMRK | syscall.ISTRIP | syscall.INLCR | syscall.IGNCR |
+		syscall.ICRNL | syscall.IXON
+	raw.Lflag &^= syscall.ECHO | syscall.ECHONL | syscall.ICANON | syscall.ISIG | syscall.IEXTEN
+	raw.Cflag &^= syscall.CSIZE | syscall.PARENB
+	raw.Cflag |= syscall.CS8
+	raw.Cc[syscall.VMIN] = 1
+	raw.Cc[syscall.VTIME] = 0
+	if err := ioctlTermios(fd, syscall.TCSETS, &raw); err != nil {
+		return nil, err
+	}
+	return &termState{termios: old}, nil
+}
+
+// restoreTerm puts back the settings makeRaw replaced.
+func restoreTerm(fd uintptr, state *termState) error {
+	return ioctlTermios(fd, syscall.TCSETS, &state.termios)
+}
@@ -0,0 +1,21 @@
+//go:build !linux
+
+// core package contains all the components needed by the summarizefiles utility.
+package core
+
+import (
+	"errors"
+)
+
+// termState type is the terminal settings to put back when leaving raw mode.
+type termState struct{}
+
+// makeRaw is only implemented on linux.
+func makeRaw(fd uintptr) (*termState, error) {
+	return nil, errors.New("the interactive browser is only supported on linux")
+}
+
+// restoreTerm is only implemented on linux.
+func restoreTerm(fd uintptr, state *termState) error {
+	return nil
+}
@@ -0,0 +1,304 @@
+// core package contains all the components needed by the summarizefiles utility.
+package core
+
+import (
+	"bufio"
+	"fmt"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+)
+
+const (
+	GranHour    = "hour"
+	GranDay     = "day"
+	GranWeek    = "week"
+	GranMonth   = "month"
+	GranQuarter = "quarter"
+	GranYear    = "year"
+)
+
+// DefaultTimeBuckets : the buckets --time uses unless told otherwise. Days for the last month, months for the last
+// year, years before that.
+const DefaultTimeBuckets = "month=30d:day,year=365d:month,older=*:year"
+
+// namedRanges : the calendar periods a bucket can name, relative to the start of the current one.
+var namedRanges = []string{
+	"today", "yesterday", "this week", "last week", "this month", "last month", "this quarter", "last quarter",
+	"this year", "last year",
+}
+
+// ageUnits : the units an age in a bucket spec can be given in, on top of the ones time.ParseDuration takes.
+var ageUnits = map[string]time.Duration{
+	"s":  time.Second,
+	"m":  time.Minute,
+	"h":  time.Hour,
+	"d":  24 * time.Hour,
+	"w":  7 * 24 * time.Hour,
+	"mo": 30 * 24 * time.Hour,
+	"q":  91 * 24 * time.Hour,
+	"y":  365 * 24 * time.Hour,
+}
+
+// TimeBucket type is one bucket of a --time summary: files younger than MaxAge, files in a named calendar period,
+// or with Any whatever is left. Files in the bucket are labeled by Granularity, or all share one label without it.
+type TimeBucket struct {
+	Name        string
+	MaxAge      time.Duration
+	Range       string
+	Any         bool
+	Granularity string
+	label       string
+}
+
+// TimeBuckets type is the buckets a --time summary sorts files into, tried in order, and the time zone labels and
+// calendar periods are worked out in.
+type TimeBuckets struct {
+	Buckets  []TimeBucket
+	Location *time.Location
+}
+
+// NewTimeBuckets construct a TimeBuckets instance from --buckets, or the --buckets-file spec, in the --tz time zone.
+func NewTimeBuckets(opts *ProgramOpts) (*TimeBuckets, error) {
+	spec := opts.Buckets
+	if opts.BucketsFile != "" {
+		var err error
+		spec, err = ReadBucketsFile(opts.BucketsFile)
+		if err != nil {
+			return nil, err
+		}
+	}
+	if spec == "" {
+		spec = DefaultTimeBuckets
+	}
+
+	loc, err := opts.Location()
+	if err != nil {
+		return nil, err
+	}
+	return ParseTimeBuckets(spec, loc)
+}
+
+// ReadBucketsFile reads a bucket spec from a file, one bucket per line or several separated by commas. Lines
+// starting with # are comments.
+func ReadBucketsFile(path string) (string, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return "", err
+	}
+	defer f.Close()
+
+	var items []string
+	scanner := bufio.NewScanner(f)
+	for scanner.Scan() {
+		line := strings.TrimSpace(scanner.Text())
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+		items = append(items, line)
+	}
+	return strings.Join(items, ","), scanner.Err()
+}
+
+// ParseTimeBuckets parses a comma separated bucket spec. Each bucket is an age ("6h", "1d", "2w", "1mo", "1y"), a
+// named calendar period ("today", "this week", "last quarter") or "*" for everything else, optionally followed by
+// ":" and the granularity its files are labeled by (hour, day, week, month, quarter, year) and preceded by "name=".
+// "6h:hour,1d,1w,1mo:day,*:month" labels the last six hours by hour, the rest of the day and the week as a whole,
+// the rest of the month by day and anything older by month.
+func ParseTimeBuckets(spec string, loc *time.Location) (*TimeBuckets, error) {
+	tb := &TimeBuckets{Location: loc}
+	var prevAge string
+	for _, item := range strings.Split(spec, ",") {
+		item = strings.TrimSpace(item)
+		if item == "" {
+			continue
+		}
+		bucket := TimeBucket{}
+
+		if eq := strings.Index(item, "="); eq >= 0 {
+			bucket.Name = strings.TrimSpace(item[:eq])
+			item = strings.TrimSpace(item[eq+1:])
+		}
+		if colon := strings.LastIndex(item, ":"); colon >= 0 {
+			bucket.Granularity = strings.ToLower(strings.TrimSpace(item[colon+1:]))
+			item = strings.TrimSpace(item[:colon])
+			if !validGranularity(bucket.Granularity) {
+				return nil, fmt.Errorf("bad time bucket %q: unknown granularity %q, expected hour, day, week, month, quarter or year",
+					item, bucket.Granularity)
+			}
+		}
+
+		when := strings.ToLower(strings.Join(strings.Fields(strings.ReplaceAll(item, "-", " ")), " "))
+		switch {
+		case when == "*":
+			bucket.Any = true
+			bucket.label = "older"
+			if prevAge != "" {
+				bucket.label = ">" + prevAge
+			}
+		case isNamedRange(when):
+			bucket.Range = when
+			bucket.label = when
+		default:
+			age, err := parseAge(item)
+			if err != nil {
+				return nil, fmt.Errorf("bad time bucket %q: %w", item, err)
+			}
+			bucket.MaxAge = age
+			bucket.label = "<" + item
+			if prevAge != "" {
+				bucket.label = prevAge + "-" + item
+			}
+			prevAge = item
+		}
+		if bucket.Name == "" {
+			bucket.Name = bucket.label
+		}
+		tb.Buckets = append(tb.Buckets, bucket)
+	}
+	if len(tb.Buckets) == 0 {
+		return nil, fmt.Errorf("no time buckets in %q", spec)
+	}
+	return tb, nil
+}
+
+// parseAge parses an age such as "6h", "3d" or "1mo", or anything time.ParseDuration takes.
+func parseAge(text string) (time.Duration, error) {
+	digits := 0
+	for digits < len(text) && text[digits] >= '0' && text[digits] <= '9' {
+		digits++
+	}
+	if unit, ok := ageUnits[strings.ToLower(text[digits:])]; ok && digits > 0 {
+		n, err := strconv.Atoi(text[:digits])
+		if err != nil {
+			return 0, err
+		}
+		return time.Duration(n) * unit, nil
+	}
+	age, err := time.ParseDuration(text)
+	if err != nil {
+		return 0, fmt.Errorf("expected an age like 6h, 1d, 2w, 1mo or 1y, or a named period like today or last week")
+	}
+	return age, nil
+}
+
+// isNamedRange reports whether when names one of the namedRanges.
+func isNamedRange(when string) bool {
+	for _, name := range namedRanges {
+		if when == name {
+			return true
+		}
+	}
+	return false
+}
+
+// validGranularity reports whether gran is one of the granularities labels can be given at.
+func validGranularity(gran string) bool {
+	switch gran {
+	case GranHour, GranDay, GranWeek, GranMonth, GranQuarter, GranYear:
+		return true
+	}
+	return false
+}
+
+// Group sorts a modification time into its bucket as of now. The group is the bucket's name prefixed by its
+// position, so groups sort in spec order. Times in no bucket are grouped as "older".
+func (tb *TimeBuckets) Group(modtime time.Time, now time.Time) (string, string) {
+	modtime = modtime.In(tb.Location)
+	now = now.In(tb.Location)
+	for idx, bucket := range tb.Buckets {
+		if !bucket.contains(modtime, now) {
+			continue
+		}
+		label := bucket.label
+		if bucket.Granularity != "" {
+			label = FormatTimeLabel(modtime, bucket.Granularity)
+		}
+		return fmt.Sprintf("%02d%s", idx+1, bucket.Name), label
+	}
+	return fmt.Sprintf("%02dolder", len(tb.Buckets)+1), "older"
+}
+
+// Label returns the label the --by time key gives a modification time. It is the --time label, qualified by the
+// bucket name when another bucket labels by the same granularity, so the same label never falls in two buckets.
+func (tb *TimeBuckets) Label(modtime time.Time, now time.Time) string {
+	group, label := tb.Group(modtime, now)
+	idx, _ := strconv.Atoi(group[:2])
+	if idx < 1 || idx > len(tb.Buckets) || tb.Buckets[idx-1].Granularity == "" {
+		return label
+	}
+	for other, bucket := range tb.Buckets {
+		if other != idx-1 && bucket.Granularity == tb.Buckets[idx-1].Granularity {
+			return label + " (" + tb.Buckets[idx-1].Name + ")"
+		}
+	}
+	return label
+}
+
+// contains reports whether a modification time falls in the bucket as of now.
+func (bucket *TimeBucket) contains(modtime time.Time, now time.Time) bool {
+	if bucket.Any {
+		return true
+	}
+	if bucket.Range != "" {
+		start, end := rangeBounds(bucket.Range, now)
+		return !modtime.Before(start) && modtime.Before(end)
+	}
+	return now.Sub(modtime) < bucket.MaxAge
+}
+
+// rangeBounds returns the start and end of a named calendar period as of now. Weeks start on Monday, as ISO weeks do.
+func rangeBounds(name string, now time.Time) (time.Time, time.Time) {
+	year, month, day := now.Date()
+	loc := now.Location()
+	today := time.Date(year, month, day, 0, 0, 0, 0, loc)
+	weekday := (int(today.Weekday()) + 6) % 7
+	week := today.AddDate(0, 0, -weekday)
+	monthStart := time.Date(year, month, 1, 0, 0, 0, 0, loc)
+	quarter := time.Date(year, month-(month-1)%3, 1, 0, 0, 0, 0, loc)
+	yearStart := time.Date(year, 1, 1, 0, 0, 0, 0, loc)
+
+	switch name {
+	case "today":
+		return today, today.AddDate(0, 0, 1)
+	case "yesterday":
+		return today.AddDate(0, 0, -1), today
+	case "this week":
+		return week, week.AddDate(0, 0, 7)
+	case "last week":
+		return week.AddDate(0, 0, -7), week
+	case "this month":
+		return monthStart, monthStart.AddDate(0, 1, 0)
+	case "last month":
+		return monthStart.AddDate(0, -1, 0), monthStart
+	case "this quarter":
+		return quarter, quarter.AddDate(0, 3, 0)
+	case "last quarter":
+		return quarter.AddDate(0, -3, 0), quarter
+	case "this year":
+		return yearStart, yearStart.AddDate(1, 0, 0)
+	case "last year":
+		return yearStart.AddDate(-1, 0, 0), yearStart
+	}
+	return now, now
+}
+
+// FormatTimeLabel formats a time as the label of the period of the given granularity it falls in, such that later
+// periods sort after earlier ones: "2024-07-18 14h", "2024-07-18", "2024-W29", "2024-07", "2024-Q3" or "2024".
+func FormatTimeLabel(t time.Time, gran string) string {
+	switch gran {
+	case GranHour:
+		return t.Format("2006-01-02 15h")
+	case GranWeek:
+		year, week := t.ISOWeek()
+		return fmt.Sprintf("%04d-W%02d", year, week)
+	case GranMonth:
+		return t.Format("2006-01")
+	case GranQuarter:
+		return fmt.Sprintf("%04d-Q%d", t.Year(), (int(t.Month())-1)/3+1)
+	case GranYear:
+		return t.Format("2006")
+	}
+	return t.Format(DateOnly)
+}
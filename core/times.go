@@ -0,0 +1,56 @@
+// core package contains all the components needed by the summarizefiles utility.
+package core
+
+import (
+	"os"
+	"time"
+)
+
+const (
+	TimeFieldMtime = "mtime"
+	TimeFieldAtime = "atime"
+	TimeFieldCtime = "ctime"
+	TimeFieldBtime = "btime"
+)
+
+// TimeFields : the timestamps --time-field can pick.
+var TimeFields = []string{TimeFieldMtime, TimeFieldAtime, TimeFieldCtime, TimeFieldBtime}
+
+// timeFieldVerbs : how reports describe the span of each timestamp.
+var timeFieldVerbs = map[string]string{
+	TimeFieldMtime: "modified",
+	TimeFieldAtime: "accessed",
+	TimeFieldCtime: "changed",
+	TimeFieldBtime: "created",
+}
+
+// GetTimeField returns the timestamp files are grouped and dated by, mtime unless --time-field says otherwise.
+func (opts *ProgramOpts) GetTimeField() string {
+	if opts.TimeField == "" {
+		return TimeFieldMtime
+	}
+	return opts.TimeField
+}
+
+// FileTime reads the timestamp --time-field picks for a file: its modification, access, status change or birth
+// time. The modification time stands in for a field the platform or filesystem doesn't record, such as the birth
+// time on older kernels.
+func FileTime(path string, info os.FileInfo, field string) time.Time {
+	if field == "" || field == TimeFieldMtime {
+		return info.ModTime()
+	}
+	if t, ok := fileTimes(path, info, field); ok {
+		return t
+	}
+	return info.ModTime()
+}
+
+// ValidTimeField reports whether field is one of the TimeFields.
+func ValidTimeField(field string) bool {
+	for _, name := range TimeFields {
+		if field == name {
+			return true
+		}
+	}
+	return false
+}
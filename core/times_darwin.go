@@ -0,0 +1,27 @@
+//go:build darwin
+
+// core package contains all the components needed by the summarizefiles utility.
+package core
+
+import (
+	"os"
+	"syscall"
+	"time"
+)
+
+// fileTimes reads the access, status change and birth times of a file from its stat.
+func fileTimes(path string, info os.FileInfo, field string) (time.Time, bool) {
+	st, ok := info.Sys().(*syscall.Stat_t)
+	if !ok {
+		return time.Time{}, false
+	}
+	switch field {
+	case TimeFieldAtime:
+		return time.Unix(st.Atimespec.Unix()), true
+	case TimeFieldCtime:
+		return time.Unix(st.Ctimespec.Unix()), true
+	case TimeFieldBtime:
+		return time.Unix(st.Birthtimespec.Unix()), true
+	}
+	return time.Time{}, false
+}
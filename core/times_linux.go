@@ -0,0 +1,43 @@
+//go:build linux
+
+// core package contains all the components needed by the summarizefiles utility.
+package core
+
+import (
+	"os"
+	"syscall"
+	"time"
+
+	"golang.org/x/sys/unix"
+)
+
+// fileTimes reads the access, status change and birth times of a file. Birth times come from statx, ok is false for
+// btime when the kernel, architecture or filesystem doesn't record them.
+func fileTimes(path string, info os.FileInfo, field string) (time.Time, bool) {
+	if field == TimeFieldBtime {
+		return birthTime(path)
+	}
+	st, ok := info.Sys().(*syscall.Stat_t)
+	if !ok {
+		return time.Time{}, false
+	}
+	switch field {
+	case TimeFieldAtime:
+		return time.Unix(int64(st.Atim.Sec), int64(st.Atim.Nsec)), true
+	case TimeFieldCtime:
+		return time.Unix(int64(st.Ctim.Sec), int64(st.Ctim.Nsec)), true
+	}
+	return time.Time{}, false
+}
+
+// birthTime asks statx for the birth time of a file, following a final symlink like the stat the scanner made.
+func birthTime(path string) (time.Time, bool) {
+	var stx unix.Statx_t
+	if err := unix.Statx(unix.AT_FDCWD, path, 0, unix.STATX_BTIME, &stx); err != nil {
+		return time.Time{}, false
+	}
+	if stx.Mask&unix.STATX_BTIME == 0 {
+		return time.Time{}, false
+	}
+	return time.Unix(stx.Btime.Sec, int64(stx.Btime.Nsec)), true
+}
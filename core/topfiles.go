@@ -0,0 +1,271 @@
+// core package contains all the components needed by the summarizefiles utility.
+package core
+
+import (
+	"container/heap"
+	"path/filepath"
+	"sort"
+	"time"
+)
+
+// DetailLimit : how many of the largest, newest and oldest files are kept for each entry when browsing without --top.
+const DetailLimit = 20
+
+// maxDetailDirs : how many directories are tallied for each entry. When the tally fills up the smaller half is
+// dropped, so top directories are approximate on very wide trees.
+const maxDetailDirs = 4096
+
+// FileStat type is what is remembered about an individual file for the top N lists. Time is the timestamp
+// --time-field picks, the one newest and oldest go by.
+type FileStat struct {
+	Path      string    `json:"path"`
+	Size      uint64    `json:"size"`
+	Allocated uint64    `json:"allocated"`
+	Time      time.Time `json:"time"`
+}
+
+// NewFileStat construct a FileStat instance for a scanned file.
+func NewFileStat(rec *FileRecord) FileStat {
+	return FileStat{Path: rec.Path, Size: uint64(rec.Info.Size()), Allocated: rec.Allocated, Time: rec.When()}
+}
+
+// FileHeap type keeps the Limit best files offered to it. It's a min heap ordered by ranksBelow, so the root is the
+// worst file kept and memory stays bounded however many files are offered.
+type FileHeap struct {
+	Limit      int
+	ranksBelow func(a FileStat, b FileStat) bool
+	files      []FileStat
+}
+
+// NewFileHeap construct a FileHeap instance. ranksBelow(a, b) reports whether a is a worse pick than b.
+func NewFileHeap(limit int, ranksBelow func(a FileStat, b FileStat) bool) *FileHeap {
+	return &FileHeap{Limit: limit, ranksBelow: ranksBelow}
+}
+
+// BySize ranks files by size, largest best.
+func BySize(a FileStat, b FileStat) bool {
+	if a.Size != b.Size {
+		return a.Size < b.Size
+	}
+	return a.Path > b.Path
+}
+
+// ByAllocated ranks files by the space they take on disk, largest best.
+func ByAllocated(a FileStat, b FileStat) bool {
+	if a.Allocated != b.Allocated {
+		return a.Allocated < b.Allocated
+	}
+	return a.Path > b.Path
+}
+
+// LargestBy is the ranking of the largest files for the size --size picks.
+func LargestBy(opts *ProgramOpts) func(a FileStat, b FileStat) bool {
+	if opts.Size == SizeAllocated {
+		return ByAllocated
+	}
+	return BySize
+}
+
+// ByNewest ranks files by modification time, most recent best.
+func ByNewest(a FileStat, b FileStat) bool {
+	if !a.Time.Equal(b.Time) {
+		return a.Time.Before(b.Time)
+	}
+	return a.Path > b.Path
+}
+
+// ByOldest ranks files by modification time, least recent best.
+func ByOldest(a FileStat, b FileStat) bool {
+	if !a.Time.Equal(b.Time) {
+		return a.Time.After(b.Time)
+	}
+	return a.Path > b.Path
+}
+
+func (fh *FileHeap) Len() int           { return len(fh.files) }
+func (fh *FileHeap) Less(i, j int) bool { return fh.ranksBelow(fh.files[i], fh.files[j]) }
+func (fh *FileHeap) Swap(i, j int)      { fh.files[i], fh.files[j] = fh.files[j], fh.files[i] }
+func (fh *FileHeap) Push(x interface{}) { fh.files = append(fh.files, x.(FileStat)) }
+func (fh *FileHeap) Pop() interface{} {
+	last := fh.files[len(fh.files)-1]
+	fh.files = fh.files[:len(fh.files)-1]
+	return last
+}
+
+// Offer keeps f if it's better than the worst file kept, or there is still room.
+func (fh *FileHeap) Offer(f FileStat) {
+	if fh.Limit <= 0 {
+		return
+	}
+	if len(fh.files) < fh.Limit {
+		heap.Push(fh, f)
+	} else if fh.ranksBelow(fh.files[0], f) {
+		fh.files[0] = f
+		heap.Fix(fh, 0)
+	}
+}
+
+// Sorted returns the files kept, best first.
+func (fh *FileHeap) Sorted() []FileStat {
+	ret := make([]FileStat, len(fh.files))
+	copy(ret, fh.files)
+	sort.Slice(ret, func(i, j int) bool {
+		return fh.ranksBelow(ret[j], ret[i])
+	})
+	return ret
+}
+
+// DirSize type is a directory and the bytes an entry's files take up in it.
+type DirSize struct {
+	Dir   string
+	Bytes uint64
+	Files int
+}
+
+// TopFiles type keeps the largest, most recently modified and least recently modified files seen, at most Limit of
+// each, however many files are added.
+type TopFiles struct {
+	Largest *FileHeap
+	Newest  *FileHeap
+	Oldest  *FileHeap
+}
+
+// NewTopFiles construct a TopFiles instance, ranking the largest files with largest.
+func NewTopFiles(limit int, largest func(a FileStat, b FileStat) bool) *TopFiles {
+	tf := &TopFiles{}
+	tf.Largest = NewFileHeap(limit, largest)
+	tf.Newest = NewFileHeap(limit, ByNewest)
+	tf.Oldest = NewFileHeap(limit, ByOldest)
+	return tf
+}
+
+// Add offers a file to each of the top lists.
+func (tf *TopFiles) Add(f FileStat) {
+	tf.Largest.Offer(f)
+	tf.Newest.Offer(f)
+	tf.Oldest.Offer(f)
+}
+
+// EntryDetail type keeps the files behind a summary entry: its top files and, for drilling down, the directories
+// holding the most bytes.
+type EntryDetail struct {
+	*TopFiles
+	// Dirs is nil unless directories are tallied, they are only shown when browsing
+	Dirs      map[string]DirSize
+	allocated bool
+}
+
+// NewEntryDetail construct an EntryDetail instance keeping TopLimit of each top file list.
+func NewEntryDetail(opts *ProgramOpts) *EntryDetail {
+	ed := &EntryDetail{TopFiles: NewTopFiles(opts.TopLimit(), LargestBy(opts))}
+	if opts.Interactive {
+		ed.Dirs = make(map[string]DirSize)
+	}
+	ed.allocated = opts.Size == SizeAllocated
+	return ed
+}
+
+// Add tallies a file into the detail. Directories are tallied by the size --size picks.
+func (ed *EntryDetail) Add(rec *FileRecord) {
+	ed.TopFiles.Add(NewFileStat(rec))
+	if ed.Dirs == nil {
+		return
+	}
+	fsize, allocated := rec.Sizes()
+	if ed.allocated {
+		fsize = allocated
+	}
+
+	dir := filepath.Dir(rec.Path)
+	ds, ok := ed.Dirs[dir]
+	if !ok && len(ed.Dirs) >= maxDetailDirs {
+		ed.pruneDirs()
+	}
+	ds.Dir = dir
+	ds.Bytes += fsize
+	ds.Files++
+	ed.Dirs[dir] = ds
+}
+
+// pruneDirs drops the smaller half of the directory tally.
+func (ed *EntryDetail) pruneDirs() {
+	dirs := ed.TopDirs(len(ed.Dirs) / 2)
+	ed.Dirs = make(map[string]DirSize, maxDetailDirs)
+	for _, ds := range dirs {
+		ed.Dirs[ds.Dir] = ds
+	}
+}
+
+// TopDirs returns the n directories holding the most bytes, largest first.
+func (ed *EntryDetail) TopDirs(n int) []DirSize {
+	dirs := make([]DirSize, 0, len(ed.Dirs))
+	for _, ds := range ed.Dirs {
+		dirs = append(dirs, ds)
+	}
+	sort.Slice(dirs, func(i, j int) bool {
+		if dirs[i].Bytes != dirs[j].Bytes {
+			return dirs[i].Bytes > dirs[j].Bytes
+		}
+		return dirs[i].Dir < dirs[j].Dir
+	})
+	if len(dirs) > n {
+		dirs = dirs[:n]
+	}
+	return dirs
+}
+
+// DetailKey is the key of an entry's detail: its group and label.
+func DetailKey(group string, label string) string {
+	return group + "\x00" + label
+}
+
+// TopLimit is how many of the largest, newest and oldest files to keep for the scan and for each entry: --top, or
+// DetailLimit when browsing. Zero when nothing will show them.
+func (opts *ProgramOpts) TopLimit() int {
+	if opts.Top > 0 {
+		return opts.Top
+	}
+	if opts.Interactive {
+		return DetailLimit
+	}
+	return 0
+}
+
+// trackDetail tallies a file into the detail of the entry it was added to. Details are only kept when something
+// will show them. Further hard links to a file are left out, like their bytes.
+func (fs *FileSummary) trackDetail(popts *ProgramOpts, group string, label string, rec *FileRecord) {
+	if popts.TopLimit() == 0 || rec.Linked {
+		return
+	}
+	if fs.Details == nil {
+		fs.Details = make(map[string]*EntryDetail)
+	}
+	key := DetailKey(group, label)
+	ed, ok := fs.Details[key]
+	if !ok {
+		ed = NewEntryDetail(popts)
+		fs.Details[key] = ed
+	}
+	ed.Add(rec)
+}
+
+// trackTop offers a file to the top lists of the whole scan.
+func (fs *FileSummary) trackTop(popts *ProgramOpts, rec *FileRecord) {
+	limit := popts.TopLimit()
+	if limit == 0 || rec.Linked {
+		return
+	}
+	if fs.Top == nil {
+		fs.Top = NewTopFiles(limit, LargestBy(popts))
+	}
+	fs.Top.Add(NewFileStat(rec))
+}
+
+// EntryTop returns the top files of an entry, nil when they weren't kept.
+func (fs *FileSummary) EntryTop(entry SummaryEntry) *TopFiles {
+	ed, ok := fs.Details[DetailKey(entry.Group, entry.Label)]
+	if !ok {
+		return nil
+	}
+	return ed.TopFiles
+}
@@ -0,0 +1,469 @@
+// core package contains all the components needed by the summarizefiles utility.
+package core
+
+import (
+	"fmt"
+	"os"
+	"strings"
+	"syscall"
+	"time"
+)
+
+const (
+	keyUp = iota + 1
+	keyDown
+	keyPageUp
+	keyPageDown
+	keyHome
+	keyEnd
+	keyEnter
+	keyBack
+	keySort
+	keyQuit
+)
+
+// parseKeys turns the bytes read from a raw terminal into browser keys. Arrow and paging keys arrive as escape
+// sequences, vi style letters work too. Anything else is ignored.
+func parseKeys(buf []byte) []int {
+	var keys []int
+	for idx := 0; idx < len(buf); idx++ {
+		c := buf[idx]
+		if c == 0x1b {
+			if idx+2 < len(buf) && (buf[idx+1] == '[' || buf[idx+1] == 'O') {
+				// a control sequence ends with a byte in the range @ to ~
+				end := idx + 2
+				for end < len(buf) && (buf[end] < 0x40 || buf[end] > 0x7e) {
+					end++
+				}
+				if end >= len(buf) {
+					break
+				}
+				switch string(buf[idx+2 : end+1]) {
+				case "A":
+					keys = append(keys, keyUp)
+				case "B":
+					keys = append(keys, keyDown)
+				case "C":
+					keys = append(keys, keyEnter)
+				case "D":
+					keys = append(keys, keyBack)
+				case "5~":
+					keys = append(keys, keyPageUp)
+				case "6~":
+					keys = append(keys, keyPageDown)
+				case "H", "1~", "7~":
+					keys = append(keys, keyHome)
+				case "F", "4~", "8~":
+					keys = append(keys, keyEnd)
+				}
+				idx = end
+				continue
+			}
+			// a lone escape
+			keys = append(keys, keyBack)
+			continue
+		}
+
+		switch c {
+		case 'q', 'Q', 3:
+			keys = append(keys, keyQuit)
+		case 's', 'S':
+			keys = append(keys, keySort)
+		case '\r', '\n', 'l':
+			keys = append(keys, keyEnter)
+		case 127, 8, 'h':
+			keys = append(keys, keyBack)
+		case 'k':
+			keys = append(keys, keyUp)
+		case 'j':
+			keys = append(keys, keyDown)
+		case ' ':
+			keys = append(keys, keyPageDown)
+		case 'g':
+			keys = append(keys, keyHome)
+		case 'G':
+			keys = append(keys, keyEnd)
+		}
+	}
+	return keys
+}
+
+// Browser type is the full screen interactive view of a scan. It shows the entries as they are summarized, one per
+// line, and drills into the largest files and directories behind an entry.
+type Browser struct {
+	Opts    *ProgramOpts
+	Summ    *FileSummary
+	Scanner *Scanner
+
+	sortidx  int
+	selected int
+	selkey   string
+	offset   int
+	entries  EntryList
+	// detail is the entry drilled into, nil when showing the list
+	detail  *SummaryEntry
+	doffset int
+	dlines  []string
+	rows    int
+
+	scanning bool
+}
+
+// NewBrowser construct a Browser instance for a scan that hasn't been started yet.
+func NewBrowser(opts *ProgramOpts, summ *FileSummary, scanner *Scanner) *Browser {
+	return &Browser{Opts: opts, Summ: summ, Scanner: scanner}
+}
+
+// Run starts the scan and browses it until the user quits. The browser stays open once the scan is done. Quitting
+// early stops the scan. Returns the scan's error.
+func (b *Browser) Run() error {
+	stdin := uintptr(syscall.Stdin)
+	state, err := makeRaw(stdin)
+	if err != nil {
+		return fmt.Errorf("can't browse interactively: %w", err)
+	}
+	defer restoreTerm(stdin, state)
+
+	// use the alternate screen so the shell's scrollback survives, and hide the cursor
+	fmt.Print("\033[?1049h\033[?25l")
+	defer fmt.Print("\033[?25h\033[?1049l")
+
+	keys := make(chan []int)
+	go func() {
+		buf := make([]byte, 64)
+		for {
+			n, err := os.Stdin.Read(buf)
+			if err != nil {
+				close(keys)
+				return
+			}
+			keys <- parseKeys(buf[:n])
+		}
+	}()
+
+	done := make(chan error, 1)
+	b.scanning = true
+	go func() {
+		done <- b.Scanner.Run()
+	}()
+
+	ticker := time.NewTicker(b.Scanner.Interval)
+	defer ticker.Stop()
+
+	var scanErr error
+	for {
+		b.draw()
+		select {
+		case pressed, ok := <-keys:
+			if !ok {
+				pressed = []int{keyQuit}
+			}
+			for _, key := range pressed {
+				if key == keyQuit {
+					if b.scanning {
+						b.Scanner.Stop()
+						scanErr = <-done
+					}
+					return scanErr
+				}
+				b.handle(key)
+			}
+		case scanErr = <-done:
+			b.scanning = false
+		case <-ticker.C:
+			// redraw to show progress and pick up terminal resizes
+		}
+	}
+}
+
+// sortKey is the key the list is currently sorted by.
+func (b *Browser) sortKey() string {
+	return SortKeys[b.sortidx]
+}
+
+// handle acts on a key press.
+func (b *Browser) handle(key int) {
+	page := b.rows
+	if page < 1 {
+		page = 1
+	}
+
+	if b.detail != nil {
+		switch key {
+		case keyBack:
+			b.detail = nil
+		case keyUp:
+			b.doffset--
+		case keyDown:
+			b.doffset++
+		case keyPageUp:
+			b.doffset -= page
+		case keyPageDown:
+			b.doffset += page
+		case keyHome:
+			b.doffset = 0
+		case keyEnd:
+			b.doffset = len(b.dlines)
+		}
+		return
+	}
+
+	switch key {
+	case keyUp:
+		b.selected--
+	case keyDown:
+		b.selected++
+	case keyPageUp:
+		b.selected -= page
+	case keyPageDown:
+		b.selected += page
+	case keyHome:
+		b.selected = 0
+	case keyEnd:
+		b.selected = len(b.entries) - 1
+	case keySort:
+		b.sortidx = (b.sortidx + 1) % len(SortKeys)
+		// nothing to sort by without line counts
+		if b.sortKey() == "lines" && !b.Opts.Lines {
+			b.sortidx = (b.sortidx + 1) % len(SortKeys)
+		}
+		if b.sortKey() == "code" && !b.Opts.Sloc {
+			b.sortidx = (b.sortidx + 1) % len(SortKeys)
+		}
+	case keyEnter:
+		if b.selected >= 0 && b.selected < len(b.entries) {
+			entry := b.entries[b.selected]
+			b.detail = &entry
+			b.doffset = 0
+		}
+	}
+	if b.selected >= 0 && b.selected < len(b.entries) {
+		b.selkey = DetailKey(b.entries[b.selected].Group, b.entries[b.selected].Label)
+	}
+}
+
+// draw redraws the whole screen from a consistent snapshot of the summary.
+func (b *Browser) draw() {
+	cols, rows, err := getConsoleSize()
+	if err != nil || cols <= 0 || rows <= 0 {
+		cols, rows = defaultConsoleSize()
+	}
+	b.Opts.ConCols = cols
+	b.Opts.ConRows = rows - 3
+	// the status and header lines take two rows
+	b.rows = rows - 2
+	if b.rows < 1 {
+		b.rows = 1
+	}
+
+	var status string
+	b.Scanner.Locked(func() {
+		b.Summ.SetDisplayRootPath(b.Opts)
+		status = StatusLine(b.Opts, b.Summ)
+		b.entries = SortedEntries(b.Opts, b.Summ)
+		if b.detail != nil {
+			b.dlines = b.detailLines(cols)
+		}
+	})
+	SortEntryList(b.Opts, b.entries, b.sortKey())
+
+	// keep the selection on the same entry while the scan reorders the list
+	if b.selkey != "" {
+		for idx, entry := range b.entries {
+			if DetailKey(entry.Group, entry.Label) == b.selkey {
+				b.selected = idx
+				break
+			}
+		}
+	}
+	if b.selected >= len(b.entries) {
+		b.selected = len(b.entries) - 1
+	}
+	if b.selected < 0 {
+		b.selected = 0
+	}
+
+	lines := make([]string, 0, rows)
+	lines = append(lines, status)
+	if b.detail != nil {
+		lines = append(lines, fitLine(b.helpLine("esc/left back  up/down scroll  q quit"), cols))
+		lines = append(lines, b.scrollDetail()...)
+	} else {
+		lines = append(lines, fitLine(b.helpLine("up/down move  enter details  s sort  q quit"), cols))
+		lines = append(lines, b.listLines(cols)...)
+	}
+
+	var sb strings.Builder
+	sb.WriteString("\033[H")
+	for idx, line := range lines {
+		if idx >= rows {
+			break
+		}
+		if idx > 0 {
+			sb.WriteString("\r\n")
+		}
+		sb.WriteString(line)
+		sb.WriteString("\033[K")
+	}
+	sb.WriteString("\033[J")
+	os.Stdout.WriteString(sb.String())
+}
+
+// helpLine is the second line of the screen: the keys, the sort order and whether the scan is still running.
+func (b *Browser) helpLine(keys string) string {
+	state := "done"
+	if b.scanning {
+		state = "scanning " + string([]rune(spinners)[tick%4])
+		tick++
+	}
+	return fmt.Sprintf("%s  [sort: %s] [%d entries] %s", keys, b.sortKey(), len(b.entries), state)
+}
+
+// listLines formats the visible part of the entry list, the selected entry in reverse video.
+func (b *Browser) listLines(cols int) []string {
+	visible := b.rows - 1
+	if visible < 1 {
+		visible = 1
+	}
+	if b.selected < b.offset {
+		b.offset = b.selected
+	}
+	if b.selected >= b.offset+visible {
+		b.offset = b.selected - visible + 1
+	}
+	if b.offset > len(b.entries)-visible {
+		b.offset = len(b.entries) - visible
+	}
+	if b.offset < 0 {
+		b.offset = 0
+	}
+
+	lines := make([]string, 0, visible+1)
+	lines = append(lines, browserHeader(b.Opts, cols))
+	for idx := b.offset; idx < len(b.entries) && idx < b.offset+visible; idx++ {
+		line := FormatBrowserEntry(b.Opts, b.entries[idx], cols)
+		if idx == b.selected {
+			line = "\033[7m" + line + "\033[0m"
+		}
+		lines = append(lines, line)
+	}
+	return lines
+}
+
+// browserNameWidth is how much of a line is left for the entry name once the figures are laid out.
+func browserNameWidth(opts *ProgramOpts, cols int) int {
+	figures := 10 + 10 + 12
+	if opts.Lines {
+		figures += 12
+	}
+	if opts.Sloc {
+		figures += 3 * 10
+	}
+	room := cols - figures
+	if room < 10 {
+		room = 10
+	}
+	return room
+}
+
+// browserHeader is the column header above the entry list.
+func browserHeader(opts *ProgramOpts, cols int) string {
+	room := browserNameWidth(opts, cols)
+	header := fmt.Sprintf("%-*s %9s %9s", room, "label", "size", "files")
+	if opts.Lines {
+		header += fmt.Sprintf(" %11s", "lines")
+	}
+	if opts.Sloc {
+		header += fmt.Sprintf(" %9s %9s %9s", "code", "comment", "blank")
+	}
+	header += fmt.Sprintf(" %11s", "newest")
+	return fitLine(header, cols)
+}
+
+// FormatBrowserEntry formats an entry as one line of the browser: its name, size, file count, line count when
+// counting lines, and its newest modification date. Long names lose their start.
+func FormatBrowserEntry(opts *ProgramOpts, entry SummaryEntry, cols int) string {
+	name := entry.Label
+	if entry.Group != "" && (opts.Dir || opts.Pivot()) {
+		name = entry.Group + " [" + entry.Label + "]"
+	}
+	room := browserNameWidth(opts, cols)
+	if len(name) > room {
+		name = ".." + name[len(name)-room+2:]
+	}
+
+	line := fmt.Sprintf("%-*s %9s %9d", room, name, humansize(opts.EntrySize(entry)), entry.FileCount)
+	if opts.Lines {
+		line += fmt.Sprintf(" %11d", entry.LineCount)
+	}
+	if opts.Sloc {
+		line += fmt.Sprintf(" %9d %9d %9d", entry.CodeLines, entry.CommentLines, entry.BlankLines)
+	}
+	line += fmt.Sprintf(" %11s", entry.MaxModTime.Format(DateOnly))
+	return fitLine(line, cols)
+}
+
+// detailLines formats what is known about the files behind the entry drilled into. Called with the scanner locked.
+func (b *Browser) detailLines(cols int) []string {
+	entry := *b.detail
+	name := entry.Label
+	if entry.Group != "" {
+		name = entry.Group + " [" + entry.Label + "]"
+	}
+	// the entry itself may have grown since it was selected
+	for _, current := range b.entries {
+		if current.Group == entry.Group && current.Label == entry.Label {
+			entry = current
+			break
+		}
+	}
+
+	lines := []string{
+		fmt.Sprintf("%s: %s in %d files, %d lines, modified %s to %s", name, humansize(b.Opts.EntrySize(entry)),
+			entry.FileCount, entry.LineCount, entry.MinModTime.Format(DateOnly), entry.MaxModTime.Format(DateOnly)),
+	}
+	detail, ok := b.Summ.Details[DetailKey(entry.Group, entry.Label)]
+	if !ok {
+		return append(lines, "", "no files recorded")
+	}
+
+	lists := []struct {
+		title string
+		files []FileStat
+	}{{"Largest files:", detail.Largest.Sorted()}, {"Newest files:", detail.Newest.Sorted()},
+		{"Oldest files:", detail.Oldest.Sorted()}}
+	for _, list := range lists {
+		for _, line := range formatFileList(b.Opts, list.title, b.Summ.PathRoot(), list.files) {
+			lines = append(lines, fitLine(line, cols))
+		}
+	}
+	lines = append(lines, "", "Top directories:")
+	for _, ds := range detail.TopDirs(DetailLimit) {
+		lines = append(lines, fitLine(fmt.Sprintf("  %9s %9d files  %s", humansize(ds.Bytes), ds.Files,
+			displayPath(b.Summ.PathRoot(), ds.Dir)), cols))
+	}
+	return lines
+}
+
+// scrollDetail returns the visible part of the detail lines.
+func (b *Browser) scrollDetail() []string {
+	if b.doffset > len(b.dlines)-b.rows {
+		b.doffset = len(b.dlines) - b.rows
+	}
+	if b.doffset < 0 {
+		b.doffset = 0
+	}
+	end := b.doffset + b.rows
+	if end > len(b.dlines) {
+		end = len(b.dlines)
+	}
+	return b.dlines[b.doffset:end]
+}
+
+// fitLine truncates a line to the screen width.
+func fitLine(line string, cols int) string {
+	if len(line) > cols {
+		return line[0:cols]
+	}
+	return line
+}
@@ -5,21 +5,51 @@ import (
 	"bufio"
 	"fmt"
 	"os"
+	"path/filepath"
 	"sort"
+	"strconv"
 	"strings"
 	"syscall"
 	"time"
 	"unsafe"
 )
 
-func getConsoleSize() (width, height int, err error) {
+// termSize asks the terminal attached to fd for its size.
+func termSize(fd uintptr) (width, height int, err error) {
 	var termDim [4]uint16
-	if _, _, err := syscall.Syscall6(syscall.SYS_IOCTL, uintptr(0), uintptr(syscall.TIOCGWINSZ), uintptr(unsafe.Pointer(&termDim)), 0, 0, 0); err != 0 {
+	if _, _, err := syscall.Syscall6(syscall.SYS_IOCTL, fd, uintptr(syscall.TIOCGWINSZ), uintptr(unsafe.Pointer(&termDim)), 0, 0, 0); err != 0 {
 		return -1, -1, err
 	}
 	return int(termDim[1]), int(termDim[0]), nil
 }
 
+// getConsoleSize asks stdout for the terminal size, then stdin.
+func getConsoleSize() (width, height int, err error) {
+	width, height, err = termSize(uintptr(syscall.Stdout))
+	if err != nil {
+		width, height, err = termSize(uintptr(syscall.Stdin))
+	}
+	return width, height, err
+}
+
+// IsTerminal reports whether stdout is attached to a terminal.
+func IsTerminal() bool {
+	_, _, err := termSize(uintptr(syscall.Stdout))
+	return err == nil
+}
+
+// defaultConsoleSize is used when there is no terminal to ask: $COLUMNS and $LINES, else 80x24.
+func defaultConsoleSize() (width, height int) {
+	width, height = 80, 24
+	if cols, err := strconv.Atoi(os.Getenv("COLUMNS")); err == nil && cols > 0 {
+		width = cols
+	}
+	if lines, err := strconv.Atoi(os.Getenv("LINES")); err == nil && lines > 0 {
+		height = lines
+	}
+	return width, height
+}
+
 func ClearConsole(cls bool) {
 	// TODO: explore using https://github.com/inancgumus/screen for cross platform
 	if cls {
@@ -30,14 +60,20 @@ func ClearConsole(cls bool) {
 
 // FormatEntry formats an entry into a column width chunk of text for rendering into output window.
 func FormatEntry(opts *ProgramOpts, entry SummaryEntry, colwidth int) string {
+	if opts.Dir {
+		return formatDirEntry(opts, entry, colwidth)
+	}
 
 	var display string = ""
-	if opts.Lines {
+	if opts.Sloc {
+		display = fmt.Sprintf("%10s: %8v code %7v comment %6v blank %6d files",
+			entry.Label, entry.CodeLines, entry.CommentLines, entry.BlankLines, entry.FileCount)
+	} else if opts.Lines {
 		display = fmt.Sprintf("%10s: %10v lines in %d files",
 			entry.Label, entry.LineCount, entry.FileCount)
 	} else {
 		display = fmt.Sprintf("%10s: %10v in %d files",
-			entry.Label, humansize(uint64(entry.TotalBytes)), entry.FileCount)
+			entry.Label, humansize(opts.EntrySize(entry)), entry.FileCount)
 	}
 	//display = strings.Repeat(" ", colwidth+1)
 
@@ -45,12 +81,90 @@ func FormatEntry(opts *ProgramOpts, entry SummaryEntry, colwidth int) string {
 		return fmt.Sprintf("%s", display)
 	} else {
 		if len(display) > colwidth {
-			return display[1:colwidth]
+			return display[:colwidth]
 		}
 	}
 	return fmt.Sprintf("%-*s", colwidth, display)
 }
 
+// formatDirEntry formats a directory entry as "path: size in N files". Paths too long for the column lose their
+// leading directories rather than the end that tells them apart.
+func formatDirEntry(opts *ProgramOpts, entry SummaryEntry, colwidth int) string {
+	name := entry.Label
+	if entry.Group != "" {
+		name = entry.Group + " [" + entry.Label + "]"
+	}
+
+	var rest string
+	if opts.Sloc {
+		rest = fmt.Sprintf(": %d code %d comment %d blank in %d files", entry.CodeLines, entry.CommentLines,
+			entry.BlankLines, entry.FileCount)
+	} else if opts.Lines {
+		rest = fmt.Sprintf(": %d lines in %d files", entry.LineCount, entry.FileCount)
+	} else {
+		rest = fmt.Sprintf(": %s in %d files", humansize(opts.EntrySize(entry)), entry.FileCount)
+	}
+
+	if colwidth == -1 {
+		return name + rest
+	}
+	room := colwidth - len(rest)
+	if len(name) > room && room > 2 {
+		name = ".." + name[len(name)-room+2:]
+	}
+	display := name + rest
+	if len(display) > colwidth {
+		display = display[0:colwidth]
+	}
+	return fmt.Sprintf("%-*s", colwidth, display)
+}
+
+// RenderDirGroups lists the entries of a per directory breakdown: the largest directories first, each with its
+// largest labels first.
+func RenderDirGroups(opts *ProgramOpts, summ *FileSummary) EntryList {
+	type dirTotal struct {
+		name string
+		size uint64
+	}
+	dirs := make([]dirTotal, 0, len(summ.Groups))
+	for name, group := range summ.Groups {
+		var size uint64
+		for _, entry := range group.Entries {
+			if opts.Sloc {
+				size += uint64(entry.CodeLines)
+			} else if opts.Lines {
+				size += uint64(entry.LineCount)
+			} else {
+				size += opts.EntrySize(entry)
+			}
+		}
+		dirs = append(dirs, dirTotal{name, size})
+	}
+	sort.Slice(dirs, func(i, j int) bool {
+		if dirs[i].size != dirs[j].size {
+			return dirs[i].size > dirs[j].size
+		}
+		return dirs[i].name < dirs[j].name
+	})
+
+	allentries := make(EntryList, 0, len(dirs))
+	for _, dir := range dirs {
+		var sorted EntryList
+		if opts.Sloc {
+			sorted = SortEntriesByCode(summ.Groups[dir.name].Entries)
+		} else if opts.Lines {
+			sorted = SortEntriesByLines(summ.Groups[dir.name].Entries)
+		} else {
+			sorted = SortEntriesBySize(opts, summ.Groups[dir.name].Entries)
+		}
+		for _, entry := range sorted {
+			entry.Group = dir.name
+			allentries = append(allentries, entry)
+		}
+	}
+	return allentries
+}
+
 // RenderGroups iterates over summary groups to include all entries with information useful to display.
 func RenderGroups(popts *ProgramOpts, summ *FileSummary) EntryList {
 	// Render files, first by their group and then their label
@@ -81,6 +195,10 @@ func RenderGroups(popts *ProgramOpts, summ *FileSummary) EntryList {
 		group := summ.Groups[key]
 		// TODO: sort the entries by their label in each group
 		sorted := SortByLabels(group)
+		if !popts.Time {
+			// size classes broken down by extension, largest first
+			SortEntryList(popts, sorted, "bytes")
+		}
 
 		for sidx := range sorted {
 			allentries[allidx] = sorted[sidx]
@@ -90,13 +208,32 @@ func RenderGroups(popts *ProgramOpts, summ *FileSummary) EntryList {
 	return allentries
 }
 
+// SortedEntries lists the entries of a summary in display order: time labels and size classes by group, cross tabs
+// row by row, otherwise the largest first.
+func SortedEntries(opts *ProgramOpts, summ *FileSummary) EntryList {
+	if opts.Pivot() {
+		return PivotEntries(opts, summ)
+	} else if opts.Dir && len(summ.Groups) > 0 {
+		return RenderDirGroups(opts, summ)
+	} else if (opts.Time || opts.UsesKey(KeySize)) && !opts.Dir {
+		return RenderGroups(opts, summ)
+	} else if opts.Sloc {
+		return SortEntriesByCode(summ.Entries)
+	} else if opts.Lines {
+		return SortEntriesByLines(summ.Entries)
+	}
+	return SortEntriesBySize(opts, summ.Entries)
+}
+
 // SortByLabels returns a list of entries sorted by their label in descending order.
 func SortByLabels(group SummaryGroup) EntryList {
 	var lmap map[string]SummaryEntry = make(map[string]SummaryEntry, len(group.Entries))
 	var el EntryList = NewEntryList(len(group.Entries))
 
 	for eidx := range group.Entries {
-		lmap[group.Entries[eidx].Label] = group.Entries[eidx]
+		entry := group.Entries[eidx]
+		entry.Group = group.Name
+		lmap[entry.Label] = entry
 	}
 
 	keys := make([]string, len(lmap))
@@ -115,119 +252,347 @@ func SortByLabels(group SummaryGroup) EntryList {
 	return el
 }
 
-var spinners string = "\u2832\u2834\u2826\u2816"
-var tick int = 0
+// ColumnCapacity is the number of cells of colwidth that fit on the screen.
+func ColumnCapacity(opts *ProgramOpts, colwidth int) int {
+	dcols := int(float64(opts.ConCols) / float64(colwidth))
 
-// Render drives the logic to render entries into columns for display while executing.
-func Render(opts *ProgramOpts, summ *FileSummary) {
-	colwidth := 35
-	if opts.Time || opts.Lines {
-		colwidth = 45
+	if (dcols * colwidth) > opts.ConCols {
+		dcols -= 1
+	}
+	if dcols < 1 || opts.ConRows < 1 {
+		return 0
+	}
+	return dcols * opts.ConRows
+}
+
+// LayoutColumns renders formatted cells into text columns, filling each column top to bottom before moving to the
+// next. Cells that don't fit on the screen are dropped. Returns one string per display line.
+func LayoutColumns(opts *ProgramOpts, cells []string, colwidth int) []string {
+	rows := opts.ConRows
+	if rows < 0 {
+		rows = 0
+	}
+	linedisp := make([]string, rows)
+	if rows == 0 {
+		return linedisp
 	}
+	dcols := ColumnCapacity(opts, colwidth) / rows
 
-	linedisp := make([]string, opts.ConRows)
+	lineidx := 0
+	colidx := 1
+	for idx := 0; idx < len(cells) && colidx <= dcols; idx++ {
+		var sb strings.Builder
+		sb.WriteString(linedisp[lineidx])
+		sb.WriteString(fmt.Sprintf("|%34s", cells[idx]))
+
+		linedisp[lineidx] = sb.String()
+		lineidx++
+
+		if lineidx >= len(linedisp) {
+			// We've reached the end of the column, move to the next
+			lineidx = 0
+			colidx += 1
+		}
+	}
+	return linedisp
+}
 
-	// timeline = "%20s%12s: %30s %12s: %30s bytes: %10d errs: %4d" % ( now, "min mdate", dispmindate, "max mdate", dispmaxdate, summ.TotalBytes, showexceptions )
-	now := fmt.Sprintf("%v", time.Now())[0:19]
+var spinners string = "\u2832\u2834\u2826\u2816"
+var tick int = 0
+
+// StatusLine formats the line at the top of the display: the time, root, --time-field dates, bytes and errors.
+func StatusLine(opts *ProgramOpts, summ *FileSummary) string {
+	// timeline = "%20s%12s: %30s %12s: %30s bytes: %10d errs: %4d" % ( now, "min "+datename, dispmindate, "max "+datename, dispmaxdate, summ.TotalBytes, showexceptions )
+	now := opts.Now().Format("2006-01-02 15:04:05")
 	dispmindate := fmt.Sprintf("%v", summ.MinModTime)[0:10]
 	dispmaxdate := fmt.Sprintf("%v", summ.MaxModTime)[0:10]
+	// "min mdate", "min adate" and so on as --time-field says
+	datename := opts.GetTimeField()[0:1] + "date"
 	timeline := ""
 	if opts.ConCols > 97 {
-		timeline = fmt.Sprintf("%18s %s %11s: %11s %11s: %11s scanned: %6s errs: %3d", now, summ.RootDisplay, "min mdate", dispmindate, "max mdate", dispmaxdate, humansize(summ.Total), summ.ExceptionCount)
+		timeline = fmt.Sprintf("%18s %s %11s: %11s %11s: %11s scanned: %6s errs: %3d", now, summ.RootDisplay, "min "+datename, dispmindate, "max "+datename, dispmaxdate, humansize(opts.SummarySize(summ)), summ.ExceptionCount)
 	} else {
 		// A more compact status line for smaller terminals.
-		timeline = fmt.Sprintf("%18s %s scanned: %6s errs: %3d", now, summ.RootDisplay, humansize(summ.Total), summ.ExceptionCount)
+		timeline = fmt.Sprintf("%18s %s scanned: %6s errs: %3d", now, summ.RootDisplay, humansize(opts.SummarySize(summ)), summ.ExceptionCount)
+	}
+	if summ.ExceptionCount > 0 {
+		timeline += " (" + summ.ErrorBreakdown() + ")"
+	}
+	if opts.Sloc {
+		timeline += fmt.Sprintf(" code: %d comment: %d blank: %d", summ.Code, summ.Comments, summ.Blanks)
+	}
+	if summ.HardLinks > 0 {
+		timeline += fmt.Sprintf(" links: %d", summ.HardLinks)
+	}
+	if len(summ.SkipCounts) > 0 {
+		timeline += " skipped: " + summ.SkipBreakdown()
 	}
 	if len(timeline) > opts.ConCols {
 		timeline = timeline[0:opts.ConCols] // truncate just to be sure
 	}
-	dcols := int(float64(opts.ConCols) / float64(colwidth))
+	return timeline
+}
 
-	if (dcols * colwidth) > opts.ConCols {
-		dcols -= 1
+// RootLines formats the totals of each root of a scan over several, one line each. Nothing for a single root.
+func RootLines(opts *ProgramOpts, summ *FileSummary) []string {
+	lines := make([]string, 0, len(summ.Roots))
+	for _, rs := range summ.Roots {
+		size := rs.Total
+		if opts.Size == SizeAllocated {
+			size = rs.Allocated
+		}
+		line := fmt.Sprintf("  %s: %s in %d files", rs.Root, humansize(size), rs.Files)
+		if opts.Lines {
+			line += fmt.Sprintf(", %d lines", rs.Lines)
+		}
+		line += fmt.Sprintf(" errs: %d", rs.ExceptionCount)
+		if len(line) > opts.ConCols {
+			line = line[0:opts.ConCols]
+		}
+		lines = append(lines, line)
 	}
+	return lines
+}
 
-	var el EntryList
-	if opts.Time {
-		el = RenderGroups(opts, summ)
-	} else if opts.Lines {
-		el = SortEntriesByLines(summ.Entries)
-	} else {
-		el = SortEntriesByBytes(summ.Entries)
+// Render drives the logic to render entries into columns for display while executing.
+func Render(opts *ProgramOpts, summ *FileSummary) {
+	colwidth := 35
+	if opts.Time || opts.Lines || opts.By != "" {
+		colwidth = 45
+	}
+	if opts.Sloc {
+		colwidth = 70
+	}
+	if opts.Dir {
+		colwidth = 60
 	}
 
+	timeline := StatusLine(opts, summ)
+	rootlines := RootLines(opts, summ)
+
+	el := SortedEntries(opts, summ)
+
 	if opts.Debug {
 		fmt.Printf("len(el)=%v summ.TotalBytes=%d\n", len(el), summ.Total)
 		fmt.Printf("Groups=%+v\n", summ.Groups)
 	}
 
-	// Render the list of entry display items into text columns into the linedisp array
-	lineidx := 0
-	colidx := 1
-	for idx := 0; idx < len(el); idx++ {
-		entry := el[idx]
-		if opts.Lines {
-			/*
-				if idx == 0 {
-					fmt.Printf("\n\n\n\n\n")
-				}
-				fmt.Printf("%s: %d lines in %d files\n", entry.Label, entry.LineCount, entry.FileCount) */
+	// the per root totals take rows from the entries
+	layout := opts
+	if len(rootlines) > 0 && !opts.Batch {
+		shrunk := *opts
+		shrunk.ConRows -= len(rootlines)
+		if shrunk.ConRows < 1 {
+			shrunk.ConRows = 1
 		}
+		layout = &shrunk
+	}
+
+	var linedisp []string
+	if opts.Pivot() {
+		linedisp = RenderPivot(layout, summ)
+	} else {
+		linedisp = layoutEntries(opts, layout, el, colwidth)
+	}
+
+	if !opts.Debug && !opts.Batch {
+		ClearConsole(false)
+	}
+	fmt.Println(timeline)
+	for _, line := range rootlines {
+		fmt.Println(line)
+	}
+	// fmt.Printf("%d %d %d\n", dcols, opts.ConCols, opts.ConRows)
+	if !opts.Batch {
+		var sr rune = []rune(spinners)[tick%4]
+		tick++
+		//fmt.Println("\u2832")
+		fmt.Println(string(sr))
+	}
+
+	for idx := 0; idx < len(linedisp); idx++ {
+		fmt.Println(linedisp[idx])
+	}
+}
+
+// layoutEntries formats the entries worth displaying into columns laid out to fit the screen, or every entry in
+// batch mode.
+func layoutEntries(opts *ProgramOpts, layout *ProgramOpts, el EntryList, colwidth int) []string {
+	// Format the entries worth displaying, there is no point formatting more than fit on the screen
+	capacity := ColumnCapacity(layout, colwidth)
+	if opts.Batch {
+		capacity = len(el)
+	}
+	cells := make([]string, 0, capacity)
+	for idx := 0; idx < len(el) && len(cells) < capacity; idx++ {
+		entry := el[idx]
 
 		displayit := false
 
-		if opts.Lines {
+		if opts.UsesKey(KeySize) {
+			// the small file classes are the point of a size histogram
+			displayit = entry.FileCount > 0
+		} else if opts.Lines {
 			if entry.LineCount > 0 {
 				displayit = true
 			}
 		} else {
-			if entry.TotalBytes > 1024 {
+			if opts.EntrySize(entry) > 1024 {
 				displayit = true
 			}
 		}
 
 		if displayit {
-			var sb strings.Builder
-			sb.WriteString(linedisp[lineidx])
-			sb.WriteString(fmt.Sprintf("|%34s", FormatEntry(opts, entry, colwidth-2)))
-
-			linedisp[lineidx] = sb.String()
-			lineidx++
-
-			if lineidx >= len(linedisp) {
-				// We've reached the end of the column, move to the next
-				lineidx = 0
-				colidx += 1
-				if colidx > dcols {
-					break
-				}
+			cells = append(cells, FormatEntry(opts, entry, colwidth-2))
+		}
+	}
+	if opts.Batch {
+		layout = batchLayout(opts, colwidth, len(cells))
+	}
+	return LayoutColumns(layout, cells, colwidth)
+}
+
+// FormatCompareEntry formats a source vs. destination delta into a column width chunk of text. The marker in front
+// of the label follows FormatDelta.
+func FormatCompareEntry(opts *ProgramOpts, d EntryDelta, colwidth int) string {
+	var display string = ""
+	if opts.Lines {
+		display = fmt.Sprintf("%s%10s: %9d %9d lines (%+d)",
+			deltaMarkers[d.Status], d.Label, d.Before.LineCount, d.After.LineCount, d.DeltaLines())
+	} else {
+		before, after := opts.EntrySize(d.Before), opts.EntrySize(d.After)
+		display = fmt.Sprintf("%s%10s: %8s %8s (%7s) %d/%d files",
+			deltaMarkers[d.Status], d.Label, humansize(before), humansize(after),
+			signedsize(int64(after)-int64(before)), d.Before.FileCount, d.After.FileCount)
+	}
+
+	if colwidth == -1 {
+		return display
+	} else if len(display) > colwidth {
+		return display[:colwidth]
+	}
+	return fmt.Sprintf("%-*s", colwidth, display)
+}
+
+// SortDeltas orders deltas for display the way Render orders entries: time labels newest first within their group,
+// otherwise the largest side of each delta first.
+func SortDeltas(opts *ProgramOpts, deltas []EntryDelta) {
+	larger := func(d EntryDelta) uint64 {
+		before, after := opts.EntrySize(d.Before), opts.EntrySize(d.After)
+		if opts.Lines {
+			before, after = uint64(d.Before.LineCount), uint64(d.After.LineCount)
+		}
+		if before > after {
+			return before
+		}
+		return after
+	}
+
+	sort.SliceStable(deltas, func(i, j int) bool {
+		if opts.Time || opts.UsesKey(KeySize) {
+			if deltas[i].Group != deltas[j].Group {
+				return deltas[i].Group < deltas[j].Group
 			}
+			return deltas[i].Label > deltas[j].Label
 		}
+		return larger(deltas[i]) > larger(deltas[j])
+	})
+}
+
+// RenderCompare renders the deltas between a source and destination scan into columns, one label per cell.
+func RenderCompare(opts *ProgramOpts, src *FileSummary, dst *FileSummary, deltas []EntryDelta) {
+	colwidth := 60
+
+	now := opts.Now().Format("2006-01-02 15:04:05")
+	timeline := fmt.Sprintf("%18s %s -> %s scanned: %6s / %6s errs: %d / %d", now, src.RootDisplay, dst.RootDisplay,
+		humansize(opts.SummarySize(src)), humansize(opts.SummarySize(dst)), src.ExceptionCount, dst.ExceptionCount)
+	if len(timeline) > opts.ConCols {
+		timeline = timeline[0:opts.ConCols] // truncate just to be sure
+	}
+
+	sorted := make([]EntryDelta, len(deltas))
+	copy(sorted, deltas)
+	SortDeltas(opts, sorted)
+
+	capacity := ColumnCapacity(opts, colwidth)
+	if opts.Batch {
+		capacity = len(sorted)
+	}
+	cells := make([]string, 0, capacity)
+	for idx := 0; idx < len(sorted) && len(cells) < capacity; idx++ {
+		cells = append(cells, FormatCompareEntry(opts, sorted[idx], colwidth-2))
 	}
+	layout := opts
+	if opts.Batch {
+		layout = batchLayout(opts, colwidth, len(cells))
+	}
+	linedisp := LayoutColumns(layout, cells, colwidth)
 
-	if !opts.Debug {
+	if !opts.Debug && !opts.Batch {
 		ClearConsole(false)
 	}
 	fmt.Println(timeline)
-	// fmt.Printf("%d %d %d\n", dcols, opts.ConCols, opts.ConRows)
-	var sr rune = []rune(spinners)[tick%4]
-	tick++
-	//fmt.Println("\u2832")
-	fmt.Println(string(sr))
+	if !opts.Batch {
+		var sr rune = []rune(spinners)[tick%4]
+		tick++
+		fmt.Println(string(sr))
+	}
 
 	for idx := 0; idx < len(linedisp); idx++ {
 		fmt.Println(linedisp[idx])
 	}
 }
 
+// FormatTopFiles formats the largest, newest and oldest files of the scan as lines of text, nothing when they weren't
+// kept.
+func FormatTopFiles(opts *ProgramOpts, summ *FileSummary) []string {
+	if summ.Top == nil {
+		return nil
+	}
+	var lines []string
+	lines = append(lines, formatFileList(opts, "Largest files:", summ.PathRoot(), summ.Top.Largest.Sorted())...)
+	lines = append(lines, formatFileList(opts, "Newest files:", summ.PathRoot(), summ.Top.Newest.Sorted())...)
+	lines = append(lines, formatFileList(opts, "Oldest files:", summ.PathRoot(), summ.Top.Oldest.Sorted())...)
+	return lines
+}
+
+// formatFileList formats a titled list of files, one per line with their size and modification date.
+func formatFileList(opts *ProgramOpts, title string, root string, files []FileStat) []string {
+	lines := []string{"", title}
+	for _, file := range files {
+		size := file.Size
+		if opts.Size == SizeAllocated {
+			size = file.Allocated
+		}
+		lines = append(lines, fmt.Sprintf("  %9s  %s  %s", humansize(size), file.Time.Format(DateOnly),
+			displayPath(root, file.Path)))
+	}
+	return lines
+}
+
+// displayPath shows a path relative to the scan root, which is usually all that tells files apart. Paths are shown
+// whole when root is empty.
+func displayPath(root string, path string) string {
+	if root == "" {
+		return path
+	}
+	rel, err := filepath.Rel(root, path)
+	if err != nil || rel == "." {
+		return path
+	}
+	return rel
+}
+
+// ShowTop prints the top files of the scan below the final report.
+func ShowTop(opts *ProgramOpts, summ *FileSummary) {
+	for _, line := range FormatTopFiles(opts, summ) {
+		fmt.Println(line)
+	}
+}
+
 // Log renders the final output into a file named: file_summary.txt
 func Log(opts *ProgramOpts, summ *FileSummary) {
-	var el EntryList
-	if opts.Time {
-		el = RenderGroups(opts, summ)
-	} else {
-		el = SortEntriesByBytes(summ.Entries)
-	}
+	el := SortedEntries(opts, summ)
 
 	if opts.Log {
 		f, err := os.Create("file_summary.txt")
@@ -239,19 +604,58 @@ func Log(opts *ProgramOpts, summ *FileSummary) {
 			entry := el[idx]
 			outf.WriteString(fmt.Sprintf("%s\n", FormatEntry(opts, entry, -1)))
 		}
+		for _, line := range FormatTopFiles(opts, summ) {
+			outf.WriteString(line + "\n")
+		}
 		outf.Flush()
 		f.Sync()
 		f.Close()
-		fmt.Println("Wrote summary to file_summary.txt\n")
+		fmt.Fprintln(opts.Messages(), "Wrote summary to file_summary.txt")
 	}
 }
 
+// GetConsoleSize works out how much room there is to render into, falling back to defaultConsoleSize.
 func (opts *ProgramOpts) GetConsoleSize() {
 	if opts.ConCols == 0 {
-		opts.ConCols, opts.ConRows, _ = getConsoleSize()
-		opts.ConRows -= 3
-		fmt.Printf("calculated: rows=%v cols=%v\n", opts.ConRows, opts.ConCols)
+		cols, rows, err := getConsoleSize()
+		if err != nil || cols <= 0 || rows <= 0 {
+			cols, rows = defaultConsoleSize()
+		}
+		opts.ConCols = cols
+		opts.ConRows = rows - 3
+		if opts.ConRows < 1 {
+			opts.ConRows = 1
+		}
+		if opts.Debug {
+			fmt.Printf("calculated: rows=%v cols=%v\n", opts.ConRows, opts.ConCols)
+		}
+	}
+}
+
+// DetectBatch switches to batch mode when stdout isn't a terminal.
+func (opts *ProgramOpts) DetectBatch() {
+	if !opts.Batch && !IsTerminal() {
+		opts.Batch = true
+	}
+}
+
+// batchLayout returns options with enough rows to lay out every cell, so a batch report is never truncated.
+func batchLayout(opts *ProgramOpts, colwidth int, ncells int) *ProgramOpts {
+	layout := *opts
+	layout.ConRows = 1
+	dcols := ColumnCapacity(&layout, colwidth)
+	if dcols > 0 && ncells > dcols {
+		layout.ConRows = (ncells + dcols - 1) / dcols
 	}
+	return &layout
+}
+
+// Progress writes a plain one line progress report to stderr, used in batch mode instead of the live display.
+func Progress(opts *ProgramOpts, summ *FileSummary) {
+	// progress is about the scan under way, it keeps the wall clock even with --as-of
+	now := fmt.Sprintf("%v", time.Now())[0:19]
+	fmt.Fprintf(os.Stderr, "%s %s scanned: %s in %d files errs: %d %s\n", now, summ.Root, humansize(opts.SummarySize(summ)),
+		summ.Files, summ.ExceptionCount, summ.ErrorBreakdown())
 }
 
 // Show is called periodically to show the summary of files scanned so far.
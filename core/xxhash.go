@@ -0,0 +1,132 @@
+// core package contains all the components needed by the summarizefiles utility.
+package core
+
+import (
+	"encoding/binary"
+	"hash"
+	"math/bits"
+)
+
+// XXH64 primes, see https://github.com/Cyan4973/xxHash/blob/dev/doc/xxhash_spec.md. They're variables so the seed
+// arithmetic in Reset wraps around instead of overflowing a constant expression.
+var (
+	xxPrime1 uint64 = 11400714785074694791
+	xxPrime2 uint64 = 14029467366897019727
+	xxPrime3 uint64 = 1609587929392839161
+	xxPrime4 uint64 = 9650029242287828579
+	xxPrime5 uint64 = 2870177450012600261
+)
+
+// xxhash64 type implements the XXH64 hash with a zero seed. It's much faster than SHA-256 when all that's needed is
+// to notice content changes.
+type xxhash64 struct {
+	v1, v2, v3, v4 uint64
+	total          uint64
+	mem            [32]byte
+	memlen         int
+}
+
+// NewXXHash64 construct a hash.Hash64 computing XXH64.
+func NewXXHash64() hash.Hash64 {
+	xx := &xxhash64{}
+	xx.Reset()
+	return xx
+}
+
+func (xx *xxhash64) Reset() {
+	xx.v1 = xxPrime1 + xxPrime2
+	xx.v2 = xxPrime2
+	xx.v3 = 0
+	xx.v4 = -xxPrime1
+	xx.total = 0
+	xx.memlen = 0
+}
+
+func (xx *xxhash64) Size() int      { return 8 }
+func (xx *xxhash64) BlockSize() int { return 32 }
+
+func (xx *xxhash64) Write(b []byte) (int, error) {
+	n := len(b)
+	xx.total += uint64(n)
+
+	if xx.memlen+n < 32 {
+		xx.memlen += copy(xx.mem[xx.memlen:], b)
+		return n, nil
+	}
+
+	if xx.memlen > 0 {
+		fill := copy(xx.mem[xx.memlen:], b)
+		b = b[fill:]
+		xx.stripe(xx.mem[:])
+		xx.memlen = 0
+	}
+	for ; len(b) >= 32; b = b[32:] {
+		xx.stripe(b)
+	}
+	xx.memlen = copy(xx.mem[:], b)
+	return n, nil
+}
+
+// stripe consumes a 32 byte stripe into the accumulators.
+func (xx *xxhash64) stripe(b []byte) {
+	xx.v1 = xxRound(xx.v1, binary.LittleEndian.Uint64(b[0:8]))
+	xx.v2 = xxRound(xx.v2, binary.LittleEndian.Uint64(b[8:16]))
+	xx.v3 = xxRound(xx.v3, binary.LittleEndian.Uint64(b[16:24]))
+	xx.v4 = xxRound(xx.v4, binary.LittleEndian.Uint64(b[24:32]))
+}
+
+func (xx *xxhash64) Sum64() uint64 {
+	var h uint64
+	if xx.total >= 32 {
+		h = bits.RotateLeft64(xx.v1, 1) + bits.RotateLeft64(xx.v2, 7) +
+			bits.RotateLeft64(xx.v3, 12) + bits.RotateLeft64(xx.v4, 18)
+		h = xxMerge(h, xx.v1)
+		h = xxMerge(h, xx.v2)
+		h = xxMerge(h, xx.v3)
+		h = xxMerge(h, xx.v4)
+	} else {
+		h = xxPrime5
+	}
+	h += xx.total
+
+	b := xx.mem[:xx.memlen]
+	for ; len(b) >= 8; b = b[8:] {
+		h ^= xxRound(0, binary.LittleEndian.Uint64(b))
+		h = bits.RotateLeft64(h, 27)*xxPrime1 + xxPrime4
+	}
+	if len(b) >= 4 {
+		h ^= uint64(binary.LittleEndian.Uint32(b)) * xxPrime1
+		h = bits.RotateLeft64(h, 23)*xxPrime2 + xxPrime3
+		b = b[4:]
+	}
+	for _, c := range b {
+		h ^= uint64(c) * xxPrime5
+		h = bits.RotateLeft64(h, 11) * xxPrime1
+	}
+
+	h ^= h >> 33
+	h *= xxPrime2
+	h ^= h >> 29
+	h *= xxPrime3
+	h ^= h >> 32
+	return h
+}
+
+// Sum appends the canonical (big endian) form of the hash, matching what xxhsum prints.
+func (xx *xxhash64) Sum(b []byte) []byte {
+	var out [8]byte
+	binary.BigEndian.PutUint64(out[:], xx.Sum64())
+	return append(b, out[:]...)
+}
+
+func xxRound(acc uint64, input uint64) uint64 {
+	acc += input * xxPrime2
+	acc = bits.RotateLeft64(acc, 31)
+	return acc * xxPrime1
+}
+
+func xxMerge(acc uint64, val uint64) uint64 {
+	val = xxRound(0, val)
+	acc ^= val
+	return acc*xxPrime1 + xxPrime4
+}
@@ -2,4 +2,6 @@ module summarizefiles
 
 go 1.18
 
-require github.com/vimeo/go-magic v1.0.0 // indirect
+require github.com/vimeo/go-magic v1.0.0
+
+require golang.org/x/sys v0.30.0
@@ -14,7 +14,11 @@ The utility current supports summaizing files by:
   - lines
     Usage:
 
-    sf [flags] path
+    sf [flags] path [path ...]
+    sf snapshot [flags] --out before.json path
+    sf diff before.json after.json
+    sf compare [flags] src dst
+    sf verify manifest path
 
     The flags are:
     --help
@@ -22,13 +26,112 @@ The utility current supports summaizing files by:
     --log
     Output summary to a file after completion
     --ext
-    Summarize by extension the default
+    Summarize by extension the default. The extension is taken from the file name, tar.gz and d.ts are
+    kept whole. Files without one are summarized as (no ext), (dotfile) or (long ext).
+    --ext-case lower|keep
+    Fold extensions to lower case so JPG and jpg are one entry, or keep them apart.
+    --known-names
+    Summarize well known files without an extension, such as Makefile, LICENSE or .gitignore, by name.
+    --backups group|strip|keep
+    Group backup and temp files (foo~, .bak, .orig, .swp) as (backup), strip the suffix to count them
+    with the file they copy, or keep the suffix as their extension.
     --time
     Summarize the files by the last modification date.
+    --buckets SPEC
+    The --time buckets, tried in order: ages (6h, 1d, 2w, 1mo, 1y), named periods (today, this week,
+    last quarter) or * for the rest, each optionally followed by :hour, :day, :week (ISO), :month,
+    :quarter or :year to label its files by that period. Defaults to month=30d:day,year=365d:month,older=*:year.
+    --buckets-file FILE
+    Read the --time buckets from FILE, one per line.
+    --tz ZONE
+    Work out the --time buckets in ZONE rather than local time.
+    --time-field mtime|atime|ctime|btime
+    Group and date files by their modification, access, status change or birth time. Birth times need
+    linux 4.11 or darwin, elsewhere the modification time is used.
+    --as-of DATE
+    Group by age as of DATE (2024-07-01 or 2024-07-01T12:00:00Z) rather than now, so the same tree always
+    gives the same report and snapshots taken days apart compare.
+    --lang
+    Summarize by language, picked by file name (Makefile, Dockerfile), extension, or for scripts without
+    one the interpreter on their #! line. .h and .hpp, .yml and .yaml each count as one language.
+    --lang-map FILE
+    Override the built in language table with "Language: .ext name #!interpreter" lines.
+    --by owner|group|mode|size
+    Summarize by the user or group owning the files, to see who holds the bytes on a shared volume, by
+    permission class: setuid, setgid, world-writable, executable or plain, or by size class: 0 B, <1K,
+    <4K, <64K, <1M, <16M, <256M, <1G and >=1G. Owners without a name are shown by number. Add --ext to
+    break the size classes down by extension.
+    --by KEY,KEY
+    Cross two keys in a table, rows by the first and columns by the second, e.g. ext,month to see which
+    file types grew this month or owner,ext. On top of the keys above: ext, lang, time (the --time
+    buckets) and hour, day, week, month, quarter or year of the --time-field timestamp.
+    --cell bytes|files|lines
+    What the cells of a --by table count, lines by default with --lines, bytes otherwise.
     --lines
-    Summarize the file sizes of text files by their line count. (Requires libmagic)
+    Summarize the file sizes of text files by their line count.
+    --sloc
+    Split the line counts into code, comment and blank lines using the comment syntax of each language,
+    sorted by lines of code. Implies --lines.
+    --dir
+    Summarize by directory, each directory including everything below it. Add --ext, --time, --lang or
+    --by to break each directory down further.
+    --depth N
+    With --dir, roll directories deeper than N into their ancestor at depth N.
+    --jobs N
+    Number of files to examine in parallel. Defaults to the number of CPUs.
+    --manifest PATH
+    Write the path, size, mtime, mode and content hash of every file to PATH. Takes a single root.
+    --manifest-format ndjson|sum
+    Write the manifest as JSON lines or in the sha256sum / xxhsum layout.
+    --hash sha256|xxhash
+    Content hash used by the manifest, files are only hashed with --manifest.
+    --format json|csv|tsv|ndjson|yaml|markdown|csv-wide
+    Write the final summary in a machine readable format. csv-wide writes a two key --by table as a
+    matrix, csv as one row per cell.
+    --output PATH
+    Where --format writes the summary. Defaults to - (stdout).
+    --batch
+    Skip the live display and only print the final report. Implied when stdout isn't a terminal.
+    --size apparent|allocated
+    Sort and show by apparent size, or by the space allocated on disk (smaller for sparse files).
+    Hard links to a file already counted add no bytes either way, the link with the smallest path owns them.
+    --top N
+    Report the N largest, newest and oldest files of the scan, and of each entry in the exported summary.
+    --interactive
+    Browse the summary full screen while it is scanned and after: arrows scroll, s cycles the sort order
+    (bytes, files, lines, code, label, newest), enter lists the largest files and directories behind an entry,
+    q quits.
+    --progress
+    In batch mode write a plain progress line to stderr every few seconds.
+    --exclude PATTERN
+    Skip paths matching a glob (** crosses directories) or a regex prefixed with re:. Repeatable.
+    --include PATTERN
+    Only summarize files matching a glob or re: regex. Repeatable.
+    --exclude-from FILE
+    Read exclude patterns from FILE, one per line.
+    --respect-gitignore
+    Skip paths ignored by .gitignore and .ignore files found in the tree.
+    --one-file-system
+    Skip mount points below the root, like du -x.
+    --follow-symlinks
+    Scan the files and directories symlinks point at. Symlinks are skipped otherwise. Directories reached
+    twice, through a loop or two links, are scanned once.
+    --errors-out FILE
+    Write every path that couldn't be read to FILE: kind, operation, path and error, tab separated.
+    --fail-on-error
+    Stop at the first path that can't be read and exit non-zero, for verification jobs.
     --debug
     Ra roh, something has gone wrong let's trace it!
+
+    The commands are:
+    snapshot
+    Scan a directory and save the summary to a JSON file (--out PATH).
+    diff
+    Show per label differences between two snapshots. Exits 1 when they differ.
+    compare
+    Scan two directory trees side by side and show per label differences. Exits 1 when they differ.
+    verify
+    Re-scan a directory and report files missing, extra, resized or changed from a manifest. Exits 1 on any.
 */
 package main
 
@@ -36,7 +139,10 @@ import (
 	"flag"
 	"fmt"
 	"os"
+	"path"
 	"path/filepath"
+	"runtime"
+	"strconv"
 	"strings"
 	"summarizefiles/core"
 	"time"
@@ -57,112 +163,415 @@ options:
   --lines, -L  Summarize text files by their line count
 */
 
+// commands maps the sub commands to their implementation. Each returns the process exit code.
+var commands = map[string]func(args []string) int{
+	"snapshot": SnapshotCommand,
+	"diff":     DiffCommand,
+	"compare":  CompareCommand,
+	"verify":   VerifyCommand,
+}
+
 func main() {
-	var myopts core.ProgramOpts
+	if len(os.Args) > 1 {
+		if command, ok := commands[os.Args[1]]; ok {
+			os.Exit(command(os.Args[2:]))
+		}
+	}
 
-	logPtr := flag.Bool("log", false, "Specify to log output to file_summary.txt")
-	debugPtr := flag.Bool("debug", false, "Something don't work, time to debug!")
-	extPtr := flag.Bool("ext", false, "Summarize files by extension")
-	timePtr := flag.Bool("time", false, "Summarize files by date modified")
-	linesPtr := flag.Bool("lines", false, "Summarize files line count")
+	var myopts core.ProgramOpts
 
+	RegisterScanFlags(flag.CommandLine, &myopts)
 	flag.Parse()
-
-	myopts.Log = *logPtr
-	myopts.Debug = *debugPtr
-	myopts.Ext = *extPtr
-	myopts.Time = *timePtr
-	myopts.Lines = *linesPtr
+	myopts.DetectBatch()
 
 	if flag.NArg() == 0 {
 		fmt.Println("summarizefiles requires a directory to examine!")
 		flag.Usage()
 		os.Exit(1)
 	}
+	if err := validateOpts(&myopts); err != nil {
+		fmt.Println(err)
+		flag.Usage()
+		os.Exit(1)
+	}
 
-	fmt.Println("Summarizing Files now...")
-	_ = SummarizeFiles(flag.Arg(0), &myopts)
+	if !myopts.Batch {
+		fmt.Println("Summarizing Files now...")
+	}
+	_, err := SummarizeFiles(flag.Args(), &myopts)
+	if err != nil {
+		os.Exit(1)
+	}
+
+}
+
+// validateOpts checks the values of the flags shared by the scanning commands, and the ways they can be combined.
+func validateOpts(opts *core.ProgramOpts) error {
+	if opts.Size != core.SizeApparent && opts.Size != core.SizeAllocated {
+		return fmt.Errorf("unknown --size %s, expected %s or %s", opts.Size, core.SizeApparent, core.SizeAllocated)
+	}
+	if opts.ExtCase != core.ExtCaseLower && opts.ExtCase != core.ExtCaseKeep {
+		return fmt.Errorf("unknown --ext-case %s, expected %s or %s", opts.ExtCase, core.ExtCaseLower, core.ExtCaseKeep)
+	}
+	if opts.Backups != core.BackupsGroup && opts.Backups != core.BackupsStrip && opts.Backups != core.BackupsKeep {
+		return fmt.Errorf("unknown --backups %s, expected %s, %s or %s", opts.Backups, core.BackupsGroup, core.BackupsStrip,
+			core.BackupsKeep)
+	}
+	if opts.By != "" {
+		if _, err := core.ParseGroupKeys(opts.By); err != nil {
+			return err
+		}
+	}
+	if opts.Pivot() && opts.Dir {
+		return fmt.Errorf("--by %s crosses two keys, it can't be combined with --dir", opts.By)
+	}
+	if opts.Cell != "" && !core.ValidCellKind(opts.Cell) {
+		return fmt.Errorf("unknown --cell %s, expected %s", opts.Cell, strings.Join(core.CellKinds, ", "))
+	}
+	if opts.By != "" && (opts.Time || opts.Lang || (opts.Ext && opts.By != core.KeySize)) {
+		return fmt.Errorf("--by %s can't be combined with --time, --lang or --ext", opts.By)
+	}
+	if !core.ValidTimeField(opts.TimeField) {
+		return fmt.Errorf("unknown --time-field %s, expected %s", opts.TimeField, strings.Join(core.TimeFields, ", "))
+	}
+	if opts.Hash != "" && opts.Manifest == "" {
+		return fmt.Errorf("--hash %s is only used by --manifest, add --manifest or leave it out", opts.Hash)
+	}
+	if opts.Format != "" && !core.ValidExportFormat(opts.Format) {
+		return fmt.Errorf("unknown --format %s", opts.Format)
+	}
+	if opts.Format == "csv-wide" && !opts.Pivot() {
+		return fmt.Errorf("--format csv-wide needs --by with two keys, such as --by ext,month")
+	}
+	return nil
+}
+
+// RegisterScanFlags registers the flags shared by every command that scans a directory tree and reports on it.
+func RegisterScanFlags(fset *flag.FlagSet, myopts *core.ProgramOpts) {
+	fset.BoolVar(&myopts.Log, "log", false, "Specify to log output to file_summary.txt")
+	fset.StringVar(&myopts.Cell, "cell", "", "What the cells of a two key --by table count: bytes, files or lines. Defaults to lines with --lines, bytes otherwise")
+	fset.StringVar(&myopts.Manifest, "manifest", "", "Write a per file manifest with content hashes to this file, - for stdout")
+	fset.StringVar(&myopts.ManifestFormat, "manifest-format", core.ManifestNDJSON, "Manifest format: ndjson or sum (sha256sum compatible)")
+	fset.StringVar(&myopts.Hash, "hash", "", "Content hash for the manifest: sha256 (default) or xxhash")
+	fset.StringVar(&myopts.Format, "format", "", "Write the final summary as json, csv, tsv, ndjson, yaml, markdown or csv-wide (two key --by tables)")
+	fset.StringVar(&myopts.Output, "output", "-", "Where --format writes the summary, - for stdout")
+	fset.IntVar(&myopts.Top, "top", 0, "Report the N largest, newest and oldest files, overall and for each entry")
+	fset.BoolVar(&myopts.Interactive, "interactive", false, "Browse the summary full screen: scroll, sort and drill into entries")
+	fset.StringVar(&myopts.ErrorsOut, "errors-out", "", "Write every path that couldn't be read to this file")
+	RegisterSummaryFlags(fset, myopts)
+}
+
+// RegisterSummaryFlags registers the flags deciding what a scan summarizes and how, compare takes only these.
+func RegisterSummaryFlags(fset *flag.FlagSet, myopts *core.ProgramOpts) {
+	fset.BoolVar(&myopts.Debug, "debug", false, "Something don't work, time to debug!")
+	fset.BoolVar(&myopts.Ext, "ext", false, "Summarize files by extension")
+	fset.BoolVar(&myopts.Time, "time", false, "Summarize files by date modified")
+	fset.StringVar(&myopts.Buckets, "buckets", "", "Time buckets for --time, e.g. 6h:hour,1d,1w,1mo:day,*:month or today,this week,last quarter")
+	fset.StringVar(&myopts.BucketsFile, "buckets-file", "", "Read the --time buckets from this file, one per line")
+	fset.StringVar(&myopts.TimeField, "time-field", core.TimeFieldMtime, "Timestamp files are grouped and dated by: mtime, atime, ctime or btime")
+	fset.StringVar(&myopts.AsOf, "as-of", "", "Measure ages from this date or time instead of now, e.g. 2024-07-01, for reproducible reports")
+	fset.StringVar(&myopts.TimeZone, "tz", "", "Time zone --time buckets are worked out in, e.g. UTC or Europe/Berlin. Defaults to local time")
+	fset.StringVar(&myopts.ExtCase, "ext-case", core.ExtCaseLower, "Extension case: lower folds JPG into jpg, keep tells them apart")
+	fset.StringVar(&myopts.Backups, "backups", core.BackupsGroup, "Backup and temp files (~, .bak, .orig, .swp): group them, strip the suffix, or keep it")
+	fset.BoolVar(&myopts.KnownNames, "known-names", false, "Summarize well known files without an extension (Makefile, LICENSE) by name")
+	fset.BoolVar(&myopts.Lang, "lang", false, "Summarize files by language, detected by file name, extension and shebang")
+	fset.StringVar(&myopts.LangMap, "lang-map", "", "File of \"Language: .ext name #!interpreter\" lines overriding the built in languages")
+	fset.StringVar(&myopts.By, "by", "", "Summarize files by owner, group, mode, size, ext, lang, time or a calendar period, or two of them crossed, e.g. ext,month")
+	fset.BoolVar(&myopts.Lines, "lines", false, "Summarize files line count")
+	fset.Var((*slocFlag)(myopts), "sloc", "Count code, comment and blank lines by language, implies --lines")
+	fset.BoolVar(&myopts.Dir, "dir", false, "Summarize files by directory, rolled up into every parent directory")
+	fset.IntVar(&myopts.Depth, "depth", 0, "With --dir, roll directories deeper than this into their ancestor, 0 for no limit")
+	fset.IntVar(&myopts.Jobs, "jobs", runtime.GOMAXPROCS(0), "Number of files to examine in parallel")
+	fset.BoolVar(&myopts.Batch, "batch", false, "Skip the live display, only print the final report. Implied when stdout isn't a terminal")
+	fset.StringVar(&myopts.Size, "size", core.SizeApparent, "Size to sort and show by: apparent, or allocated on disk")
+	fset.BoolVar(&myopts.Progress, "progress", false, "In batch mode write a progress line to stderr every few seconds")
+	fset.BoolVar(&myopts.FailOnError, "fail-on-error", false, "Stop at the first path that can't be read and exit non-zero")
+	RegisterFilterFlags(fset, myopts)
+}
+
+// RegisterFilterFlags registers the flags that decide which paths a scan skips.
+func RegisterFilterFlags(fset *flag.FlagSet, myopts *core.ProgramOpts) {
+	fset.Var((*stringList)(&myopts.Excludes), "exclude", "Skip paths matching this glob, or regex when prefixed with re:. Repeatable")
+	fset.Var((*stringList)(&myopts.Includes), "include", "Only summarize files matching this glob, or regex when prefixed with re:. Repeatable")
+	fset.StringVar(&myopts.ExcludeFrom, "exclude-from", "", "Read exclude patterns from this file, one per line")
+	fset.BoolVar(&myopts.RespectGitignore, "respect-gitignore", false, "Skip paths ignored by .gitignore and .ignore files in the tree")
+	fset.BoolVar(&myopts.OneFileSystem, "one-file-system", false, "Don't cross into other filesystems mounted below the root")
+	fset.BoolVar(&myopts.FollowSymlinks, "follow-symlinks", false, "Scan what symlinks point at instead of skipping them")
+}
+
+// stringList type is a flag.Value collecting every use of a repeatable flag.
+type stringList []string
+
+func (sl *stringList) String() string {
+	return strings.Join(*sl, ",")
+}
+
+func (sl *stringList) Set(value string) error {
+	*sl = append(*sl, value)
+	return nil
+}
+
+// slocFlag type is a flag.Value setting --sloc, which implies --lines.
+type slocFlag core.ProgramOpts
+
+func (sf *slocFlag) String() string {
+	if sf == nil {
+		return "false"
+	}
+	return strconv.FormatBool(sf.Sloc)
+}
+
+func (sf *slocFlag) Set(value string) error {
+	on, err := strconv.ParseBool(value)
+	if err != nil {
+		return err
+	}
+	sf.Sloc = on
+	if on {
+		sf.Lines = true
+	}
+	return nil
+}
 
+func (sf *slocFlag) IsBoolFlag() bool {
+	return true
 }
 
 // SummarizeFile summarizes a file by program options.
-func SummarizeFile(popts *core.ProgramOpts, summ *core.FileSummary, path string, info os.FileInfo) {
+func SummarizeFile(popts *core.ProgramOpts, summ *core.FileSummary, rec *core.FileRecord) {
 
+	if popts.Dir {
+		SummarizeFileByDir(popts, summ, rec)
+		return
+	}
+	if popts.By != "" {
+		SummarizeFileByKey(popts, summ, rec)
+		return
+	}
 	if popts.Time {
-		SummarizeFileByTime(popts, summ, path, info)
+		SummarizeFileByTime(popts, summ, rec)
 		return
 	}
-	SummarizeFileByExt(popts, summ, path, info)
+	if popts.Lang {
+		SummarizeFileByLang(popts, summ, rec)
+		return
+	}
+	SummarizeFileByExt(popts, summ, rec)
+}
+
+// SummarizeFileByDir summarizes a file under each directory it lives in, optionally broken down by extension, time,
+// language or --by property.
+func SummarizeFileByDir(popts *core.ProgramOpts, summ *core.FileSummary, rec *core.FileRecord) {
+	label := ""
+	if popts.By != "" {
+		label = core.KeyLabel(popts, popts.By, rec)
+	} else if popts.Time {
+		_, label = core.GetTimeGroup(popts, rec)
+	} else if popts.Lang {
+		label = rec.Language
+	} else if popts.Ext {
+		label = FileExtension(popts, rec.Path)
+	}
+
+	dir, err := filepath.Rel(rec.Root, filepath.Dir(rec.Path))
+	if err != nil {
+		dir = "."
+	}
+	dirs := core.DirLabels(dir, popts.Depth)
+	if len(summ.Roots) > 0 {
+		// directories of different roots are told apart by their root
+		for idx := range dirs {
+			dirs[idx] = path.Join(filepath.ToSlash(rec.Root), dirs[idx])
+		}
+	}
+	summ.AddEntryByDir(popts, dirs, label, rec)
 }
 
 // SummarizeFileByTime summarizes a file by the time period it was modified.
-func SummarizeFileByTime(popts *core.ProgramOpts, summ *core.FileSummary, path string, info os.FileInfo) {
-	//group, label := core.GetTimeGroup(info)
+func SummarizeFileByTime(popts *core.ProgramOpts, summ *core.FileSummary, rec *core.FileRecord) {
+	//group, label := core.GetTimeGroup(popts, rec)
 	//fmt.Printf("%v, %v\n", group, label)
-	summ.AddEntryByTime(popts, path, info)
+	summ.AddEntryByTime(popts, rec)
 }
 
-// SummarizeFileByExt summarizes a file by it's extension.
-func SummarizeFileByExt(popts *core.ProgramOpts, summ *core.FileSummary, path string, info os.FileInfo) {
-	fcomps := strings.Split(path, ".")
-	fext := "Other"
-	lidx := len(fcomps) - 1
-	if len(fcomps) > 1 {
-		fext = fcomps[lidx]
-	}
-	if popts.Debug {
-		fmt.Printf("%v: %d %v\n", fext, len(fcomps), fcomps)
+// SummarizeFileByKey summarizes a file by the --by property: its owner, group, permission class or size class, the
+// latter broken down by extension with --ext. With two keys the file goes in the cell of their cross tab.
+func SummarizeFileByKey(popts *core.ProgramOpts, summ *core.FileSummary, rec *core.FileRecord) {
+	if popts.Pivot() {
+		keys := popts.ByKeys()
+		summ.AddEntryByKeys(popts, core.KeyLabel(popts, keys[0], rec), core.KeyLabel(popts, keys[1], rec), rec)
+		return
 	}
-	// mark fext greater than 6 as unknown
-	if len(fext) > 9 {
-		fext = "Other"
+	if popts.By == core.KeySize {
+		fext := ""
+		if popts.Ext {
+			fext = FileExtension(popts, rec.Path)
+		}
+		summ.AddEntryBySize(popts, fext, rec)
+		return
 	}
+	summ.AddEntryByExt(popts, core.KeyLabel(popts, popts.By, rec), rec)
+}
 
-	if fext == "Other" {
-		return
+// SummarizeFileByExt summarizes a file by it's extension.
+func SummarizeFileByExt(popts *core.ProgramOpts, summ *core.FileSummary, rec *core.FileRecord) {
+	fext := FileExtension(popts, rec.Path)
+
+	se := summ.AddEntryByExt(popts, fext, rec)
+	if popts.Debug {
+		fmt.Printf("%s: %d lines in %d files\n", rec.Path, se.LineCount, se.FileCount)
 	}
+}
 
-	se := summ.AddEntryByExt(popts, fext, path, info)
+// SummarizeFileByLang summarizes a file by the language it is written in. Files in no known language are kept under
+// "Other" so the languages add up to the whole tree.
+func SummarizeFileByLang(popts *core.ProgramOpts, summ *core.FileSummary, rec *core.FileRecord) {
+	summ.AddEntryByExt(popts, rec.Language, rec)
+}
+
+// FileExtension works out the extension a file is summarized under.
+func FileExtension(popts *core.ProgramOpts, path string) string {
+	fext := core.ExtensionOf(popts, path)
 	if popts.Debug {
-		fmt.Printf("%s: %d lines in %d files\n", path, se.LineCount, se.FileCount)
+		fmt.Printf("%v: %v\n", fext, path)
 	}
+	return fext
 }
 
-// SummarizeFiles main loop that drives scanning the files and summarizing them.
-func SummarizeFiles(mydir string, myopts *core.ProgramOpts) error {
-	fmt.Println(mydir)
+// LoadTimeOpts loads the clock ages are measured by and, with --time or a time --by key, the buckets files are
+// grouped into.
+func LoadTimeOpts(myopts *core.ProgramOpts) error {
+	clock, err := core.NewClock(myopts)
+	if err != nil {
+		return err
+	}
+	myopts.Clock = clock
+	if myopts.UsesTime() {
+		myopts.TimeBuckets, err = core.NewTimeBuckets(myopts)
+	}
+	return err
+}
 
-	summ := core.NewFileSummary(mydir)
-	summ.Root = mydir
-	lastshow := time.Now()
+// SummarizeFiles main loop that drives scanning the files and summarizing them. Several roots are scanned at once
+// into one summary that also keeps the totals of each. Returns the summary.
+func SummarizeFiles(roots []string, myopts *core.ProgramOpts) (*core.FileSummary, error) {
+	roots, warnings := core.NormalizeRoots(roots)
+	for _, warning := range warnings {
+		fmt.Fprintln(os.Stderr, "warning:", warning)
+	}
+	mydir := roots[0]
+
+	summ := core.NewRootsSummary(roots)
+	if myopts.Interactive && myopts.Batch {
+		err := fmt.Errorf("--interactive needs a terminal and can't be combined with --batch")
+		fmt.Fprintln(myopts.Messages(), err)
+		return &summ, err
+	}
+	if myopts.Manifest != "" && len(roots) > 1 {
+		// verify checks a single tree, its paths are relative to the one root
+		err := fmt.Errorf("--manifest takes a single root, %d were given", len(roots))
+		fmt.Fprintln(myopts.Messages(), err)
+		return &summ, err
+	}
+	if err := LoadTimeOpts(myopts); err != nil {
+		fmt.Fprintln(myopts.Messages(), err)
+		return &summ, err
+	}
+	if !myopts.Batch && !myopts.Interactive {
+		fmt.Println(summ.Root)
+	}
+
+	var manifest *core.ManifestWriter
+	if myopts.Manifest != "" {
+		if myopts.Hash == "" {
+			myopts.Hash = core.HashSHA256
+		}
+		var err error
+		manifest, err = core.NewManifestWriter(myopts.Manifest, mydir, myopts.ManifestFormat, myopts.Hash)
+		if err != nil {
+			fmt.Fprintln(myopts.Messages(), err)
+			return &summ, err
+		}
+	}
 
 	myopts.GetConsoleSize()
 	summ.SetDisplayRootPath(myopts)
 
-	core.ClearConsole(true)
+	if !myopts.Batch && !myopts.Interactive {
+		core.ClearConsole(true)
+	}
 
-	err := filepath.Walk(mydir,
-		func(path string, info os.FileInfo, err error) error {
-			if err != nil {
-				return err
-			}
-			if !info.IsDir() {
-				SummarizeFile(myopts, &summ, path, info)
-			}
-			//fmt.Println(path, info.Size())
-			if time.Since(lastshow).Milliseconds() > 300 {
-				core.Show(myopts, &summ)
-				lastshow = time.Now()
-			}
-			return nil
-		})
+	var errout *os.File
+	if myopts.ErrorsOut != "" {
+		var err error
+		errout, err = os.Create(myopts.ErrorsOut)
+		if err != nil {
+			fmt.Fprintln(myopts.Messages(), err)
+			return &summ, err
+		}
+		defer errout.Close()
+	}
+
+	var manifestErr error
+	scanner := core.NewScanner(mydir, myopts, func(rec *core.FileRecord) {
+		SummarizeFile(myopts, &summ, rec)
+		if manifest != nil && manifestErr == nil && rec.Info.Mode().IsRegular() && rec.Err == nil {
+			manifestErr = manifest.Write(rec)
+		}
+	})
+	scanner.Roots = roots
+	scanner.Skip = summ.AddSkipped
+	scanner.Error = func(serr core.ScanError) {
+		summ.AddError(serr)
+		if errout != nil {
+			fmt.Fprintln(errout, core.FormatScanError(serr))
+		}
+	}
+	// the interactive browser draws the summary itself
+	if !myopts.Batch && !myopts.Interactive {
+		scanner.Refresh = func() {
+			core.Show(myopts, &summ)
+		}
+	} else if myopts.Batch && myopts.Progress {
+		scanner.Refresh = func() {
+			core.Progress(myopts, &summ)
+		}
+		scanner.Interval = 5 * time.Second
+	}
+	var err error
+	if myopts.Interactive {
+		err = core.NewBrowser(myopts, &summ, scanner).Run()
+	} else {
+		err = scanner.Run()
+	}
+	if manifest != nil {
+		if manifestErr == nil {
+			manifestErr = manifest.Close()
+		}
+		if err == nil {
+			err = manifestErr
+		}
+	}
 	if err != nil {
-		fmt.Println(err)
+		fmt.Fprintln(myopts.Messages(), err)
+	}
+	// in batch mode a summary exported to stdout replaces the console report, so it can be piped
+	if !myopts.Pipeable() {
+		core.Show(myopts, &summ)
+		core.ShowTop(myopts, &summ)
 	}
-	core.Show(myopts, &summ)
 	if myopts.Log {
 		core.Log(myopts, &summ)
 	}
+	if myopts.Format != "" {
+		exportErr := core.Export(myopts, &summ)
+		if exportErr != nil {
+			fmt.Fprintln(myopts.Messages(), exportErr)
+			if err == nil {
+				err = exportErr
+			}
+		}
+	}
 
-	return nil
+	return &summ, err
 }
@@ -1,5 +1,6 @@
 #!/bin/bash
 
+# Only needed for the libmagic build tag: make libmagic
 # sudo dnf install -y file-devel file-libs -y
 
 go get github.com/vimeo/go-magic/magic
@@ -0,0 +1,85 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"os"
+	"summarizefiles/core"
+)
+
+// SnapshotCommand scans a directory and persists the summary for a later diff.
+func SnapshotCommand(args []string) int {
+	var myopts core.ProgramOpts
+
+	fset := flag.NewFlagSet("snapshot", flag.ExitOnError)
+	RegisterScanFlags(fset, &myopts)
+	outPtr := fset.String("out", "snapshot.json", "File to save the snapshot to")
+	fset.Parse(args)
+	myopts.DetectBatch()
+
+	if fset.NArg() != 1 {
+		fmt.Println("snapshot requires a directory to examine!")
+		fset.Usage()
+		return 2
+	}
+	if err := validateOpts(&myopts); err != nil {
+		fmt.Println(err)
+		fset.Usage()
+		return 2
+	}
+
+	summ, err := SummarizeFiles(fset.Args(), &myopts)
+	if err != nil {
+		return 2
+	}
+
+	err = core.WriteSnapshot(*outPtr, &myopts, summ)
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		return 2
+	}
+	fmt.Printf("Wrote snapshot to %s\n", *outPtr)
+	return 0
+}
+
+// DiffCommand compares two snapshots. Exits 0 when they match, 1 when they differ and 2 on trouble.
+func DiffCommand(args []string) int {
+	fset := flag.NewFlagSet("diff", flag.ExitOnError)
+	allPtr := fset.Bool("all", false, "Also show labels that didn't change")
+	fset.Parse(args)
+
+	if fset.NArg() != 2 {
+		fmt.Println("diff requires two snapshot files!")
+		fset.Usage()
+		return 2
+	}
+
+	before, err := core.ReadSnapshot(fset.Arg(0))
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		return 2
+	}
+	after, err := core.ReadSnapshot(fset.Arg(1))
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		return 2
+	}
+
+	if before.Mode != after.Mode {
+		fmt.Fprintf(os.Stderr, "warning: comparing a %s snapshot with a %s snapshot\n", before.Mode, after.Mode)
+	}
+
+	var myopts core.ProgramOpts
+	myopts.Lines = before.Lines && after.Lines
+
+	fmt.Printf("--- %s %s\n+++ %s %s\n", before.Summary.Root, before.Label(),
+		after.Summary.Root, after.Label())
+	deltas := core.DiffSummaries(before.Summary, after.Summary)
+	core.WriteDiff(os.Stdout, &myopts, before.Summary, after.Summary, deltas, *allPtr)
+
+	added, removed, changed := core.CountDifferences(deltas)
+	if added+removed+changed > 0 {
+		return 1
+	}
+	return 0
+}
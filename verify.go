@@ -0,0 +1,64 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"os"
+	"summarizefiles/core"
+)
+
+// VerifyCommand re-scans a directory against a manifest. Exits 0 when every file matches, 1 when any file is
+// missing, extra, resized or changed and 2 on trouble.
+func VerifyCommand(args []string) int {
+	var myopts core.ProgramOpts
+
+	fset := flag.NewFlagSet("verify", flag.ExitOnError)
+	fset.IntVar(&myopts.Jobs, "jobs", 0, "Number of files to examine in parallel")
+	fset.BoolVar(&myopts.FailOnError, "fail-on-error", false, "Stop at the first file that can't be read")
+	RegisterFilterFlags(fset, &myopts)
+	fset.Parse(args)
+
+	if fset.NArg() != 2 {
+		fmt.Println("verify requires a manifest and a directory!")
+		fset.Usage()
+		return 2
+	}
+
+	manifest, err := core.ReadManifest(fset.Arg(0))
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		return 2
+	}
+	myopts.Hash = manifest.Algorithm
+
+	root := fset.Arg(1)
+	fmt.Printf("Verifying %s against %d files in %s (%s)\n", root, len(manifest.Entries), fset.Arg(0), manifest.Algorithm)
+
+	verifier := core.NewVerifier(manifest, root)
+	scanner := core.NewScanner(root, &myopts, func(rec *core.FileRecord) {
+		if rec.Info.Mode().IsRegular() {
+			verifier.Check(rec)
+		}
+	})
+	scanner.Error = verifier.Error
+	err = scanner.Run()
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		return 2
+	}
+
+	results := verifier.Finish()
+	counts := make(map[string]int)
+	for _, r := range results {
+		counts[r.Problem]++
+		fmt.Println(core.FormatVerifyResult(r))
+	}
+
+	fmt.Printf("%d files checked: %d missing, %d extra, %d resized, %d changed, %d errors.\n", verifier.Checked,
+		counts[core.VerifyMissing], counts[core.VerifyExtra], counts[core.VerifyResized], counts[core.VerifyChanged],
+		counts[core.VerifyError])
+	if len(results) > 0 {
+		return 1
+	}
+	return 0
+}